@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The client type talks to the JSON API of a running Snippetbox server.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// The newClient() function returns a client for the server and token in the
+// config. The token can be empty, for the endpoints which allow anonymous
+// access.
+func newClient(cfg config) (*client, error) {
+
+	if cfg.Server == "" {
+		return nil, errors.New(`no server configured: run "snippet login <server-url>" or set SNIPPETBOX_URL`)
+	}
+
+	return &client{
+		server: strings.TrimRight(cfg.Server, "/"),
+		token:  cfg.Token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// The apiError type holds a problem details response from the server. Its
+// Error() method includes the validation errors, if there are any, so that
+// they are shown to the user.
+type apiError struct {
+	Status int               `json:"status"`
+	Title  string            `json:"title"`
+	Detail string            `json:"detail"`
+	Errors map[string]string `json:"errors"`
+}
+
+func (e *apiError) Error() string {
+
+	msg := e.Title
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+
+	for _, field := range slices.Sorted(maps.Keys(e.Errors)) {
+		msg += fmt.Sprintf("\n  %s: %s", field, e.Errors[field])
+	}
+
+	return msg
+}
+
+// The do() method sends a request to the API, and decodes a successful JSON
+// response into dst (which can be nil if there's no body). Error responses
+// are returned as an *apiError.
+func (c *client) do(method, path string, body, dst any) error {
+
+	var r io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		apiErr := &apiError{Status: res.StatusCode, Title: http.StatusText(res.StatusCode)}
+
+		// Decode the problem details if there are any. If there aren't
+		// (for example because a proxy sent the error), the status is
+		// enough to go on.
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "application/problem+json") {
+			json.NewDecoder(res.Body).Decode(apiErr)
+		}
+
+		return apiErr
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
+
+// The me() method returns the name of the user the token belongs to.
+func (c *client) me() (string, error) {
+
+	var res struct {
+		Name string `json:"name"`
+	}
+
+	err := c.do(http.MethodGet, "/api/v1/me", nil, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.Name, nil
+}
+
+// The snippetInput type holds the fields for a new snippet.
+type snippetInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Expires    int    `json:"expires"`
+	Visibility string `json:"visibility"`
+}
+
+// The create() method creates a snippet and returns it.
+func (c *client) create(input snippetInput) (models.Snippet, error) {
+
+	var res struct {
+		Snippet models.Snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodPost, "/api/v1/snippets", input, &res)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	return res.Snippet, nil
+}
+
+// The get() method returns a single snippet.
+func (c *client) get(id int) (models.Snippet, error) {
+
+	var res struct {
+		Snippet models.Snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(id), nil, &res)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	return res.Snippet, nil
+}
+
+// The list() method returns the latest public snippets.
+func (c *client) list() ([]models.Snippet, error) {
+
+	var res struct {
+		Snippets []models.Snippet `json:"snippets"`
+	}
+
+	err := c.do(http.MethodGet, "/api/v1/snippets", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Snippets, nil
+}
+
+// The snippetURL() method returns the address of a snippet's web page.
+func (c *client) snippetURL(id int) string {
+	return fmt.Sprintf("%s/snippet/view/%d", c.server, id)
+}
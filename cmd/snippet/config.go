@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The config type holds the settings saved by "snippet login": the server to
+// talk to and the API token to authenticate with.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+// The configPath() function returns where the config file is kept. This is
+// $SNIPPET_CONFIG if it's set (which is handy for tests and for keeping more
+// than one login), or snippetbox/config.json in the user's config directory
+// otherwise (~/.config on Linux).
+func configPath() (string, error) {
+
+	if path := os.Getenv("SNIPPET_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "snippetbox", "config.json"), nil
+}
+
+// The loadConfig() function reads the config file, and then applies the
+// SNIPPETBOX_URL and SNIPPETBOX_TOKEN environment variables on top of it, so
+// that CI jobs can be configured without logging in. A missing config file
+// isn't an error; the zero config is returned instead.
+func loadConfig() (config, error) {
+
+	var cfg config
+
+	path, err := configPath()
+	if err != nil {
+		return config{}, err
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return config{}, err
+	default:
+		err = json.Unmarshal(b, &cfg)
+		if err != nil {
+			return config{}, err
+		}
+	}
+
+	if v := os.Getenv("SNIPPETBOX_URL"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("SNIPPETBOX_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+
+	return cfg, nil
+}
+
+// The saveConfig() function writes the config file. The file holds a token,
+// so it's only readable by its owner.
+func saveConfig(cfg config) error {
+
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0o600)
+}
@@ -0,0 +1,271 @@
+// The snippet command is a command-line client for a running Snippetbox
+// server. It uses the JSON API, authenticating with a personal API token
+// which "snippet login" saves in a config file. For example:
+//
+//	snippet login https://snippetbox.high-la.dev
+//	git diff | snippet create --expires 7d
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// errUsage is returned when a command is called with the wrong arguments.
+var errUsage = errors.New(`usage:
+  snippet login <server-url>     save a server and API token (read from stdin)
+  snippet create [flags] [file]  create a snippet from a file, or from stdin
+      --title <title>            defaults to the file name or the first line
+      --expires <1d|7d|1y>       defaults to 1y
+      --visibility <public|unlisted|private>
+  snippet get <id>               print a snippet's content
+  snippet list                   list the latest public snippets`)
+
+func main() {
+
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "snippet:", err)
+		os.Exit(1)
+	}
+}
+
+// The run() function runs the command given by args. It takes the standard
+// streams as parameters so that the tests can supply their own.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "login":
+		return runLogin(args[1:], stdin, stdout, stderr)
+	case "create":
+		return runCreate(args[1:], stdin, stdout)
+	case "get":
+		return runGet(args[1:], stdout)
+	case "list":
+		return runList(args[1:], stdout)
+	default:
+		return errUsage
+	}
+}
+
+// The runLogin() function asks for an API token, checks that it works with
+// the server, and saves them both in the config file. The token is read from
+// stdin rather than taken as an argument, so that it doesn't end up in the
+// shell history.
+func runLogin(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	server := strings.TrimRight(args[0], "/")
+
+	fmt.Fprintf(stderr, "Create a token at %s/account/tokens and paste it here: ", server)
+
+	token, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	cfg := config{Server: server, Token: strings.TrimSpace(token)}
+	if cfg.Token == "" {
+		return errors.New("no token given")
+	}
+
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	name, err := c.me()
+	if err != nil {
+		return fmt.Errorf("checking token: %w", err)
+	}
+
+	err = saveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Logged in to %s as %s.\n", server, name)
+
+	return nil
+}
+
+// The runCreate() function creates a snippet from a file or stdin, and prints
+// its URL.
+func runCreate(args []string, stdin io.Reader, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	title := flags.String("title", "", "")
+	expires := flags.String("expires", "1y", "")
+	visibility := flags.String("visibility", "public", "")
+
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	days, err := parseExpires(*expires)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+
+	if flags.NArg() == 1 {
+		content, err = os.ReadFile(flags.Arg(0))
+		if *title == "" {
+			*title = filepath.Base(flags.Arg(0))
+		}
+	} else {
+		content, err = io.ReadAll(stdin)
+		if *title == "" {
+			*title = defaultTitle(string(content))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+
+	snippet, err := c.create(snippetInput{
+		Title:      *title,
+		Content:    string(content),
+		Expires:    days,
+		Visibility: *visibility,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, c.snippetURL(snippet.ID))
+
+	return nil
+}
+
+// The runGet() function prints a snippet's content, exactly as it was
+// created, so that it can be piped into other commands.
+func runGet(args []string, stdout io.Writer) error {
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 1 {
+		return fmt.Errorf("invalid snippet ID %q", args[0])
+	}
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+
+	snippet, err := c.get(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, snippet.Content)
+	return err
+}
+
+// The runList() function prints the ID, expiry date and title of the latest
+// public snippets, one per line.
+func runList(args []string, stdout io.Writer) error {
+
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+
+	snippets, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		fmt.Fprintf(stdout, "%d\t%s\t%s\n", s.ID, s.Expires.UTC().Format("2006-01-02"), s.Title)
+	}
+
+	return nil
+}
+
+// The loadClient() function returns a client using the saved config.
+func loadClient() (*client, error) {
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(cfg)
+}
+
+// The parseExpires() function converts an expiry like "7d" or "1y" into the
+// number of days the API expects. A plain number is taken as days too. The
+// server decides which values are allowed.
+func parseExpires(s string) (int, error) {
+
+	unit := 1
+	n := s
+
+	switch {
+	case strings.HasSuffix(s, "d"):
+		n = strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "y"):
+		n = strings.TrimSuffix(s, "y")
+		unit = 365
+	}
+
+	days, err := strconv.Atoi(n)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid expiry %q, use something like 1d, 7d or 1y", s)
+	}
+
+	return days * unit, nil
+}
+
+// The defaultTitle() function returns the title to use for a snippet read
+// from stdin: its first non-blank line, cut down to the 100 characters the
+// server allows.
+func defaultTitle(content string) string {
+
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) > 100 {
+			line = string([]rune(line)[:100])
+		}
+
+		return line
+	}
+
+	return "Untitled"
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+const testToken = "sbx_test"
+
+var testSnippet = models.Snippet{
+	ID:         1,
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...\nA frog jumps into the pond,\n",
+	Created:    time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+	Expires:    time.Date(2025, 3, 17, 10, 15, 0, 0, time.UTC),
+	Visibility: models.SnippetPublic,
+}
+
+// The newTestServer() function starts a server which fakes the parts of the
+// Snippetbox API used by the client. The last snippet created is stored in
+// created, so that tests can check what was sent.
+func newTestServer(t *testing.T, created *snippetInput) *httptest.Server {
+
+	problem := func(w http.ResponseWriter, status int, errs map[string]string) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"type":   "about:blank",
+			"title":  http.StatusText(status),
+			"status": status,
+			"errors": errs,
+		})
+	}
+
+	authorized := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+testToken
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			problem(w, http.StatusUnauthorized, nil)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": 1, "name": "Alice"})
+	})
+
+	mux.HandleFunc("GET /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"snippets": []models.Snippet{testSnippet}})
+	})
+
+	mux.HandleFunc("GET /api/v1/snippets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "1" {
+			problem(w, http.StatusNotFound, nil)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"snippet": testSnippet})
+	})
+
+	mux.HandleFunc("POST /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			problem(w, http.StatusUnauthorized, nil)
+			return
+		}
+
+		err := json.NewDecoder(r.Body).Decode(created)
+		if err != nil {
+			problem(w, http.StatusBadRequest, nil)
+			return
+		}
+
+		if created.Expires != 1 && created.Expires != 7 && created.Expires != 365 {
+			problem(w, http.StatusUnprocessableEntity, map[string]string{"expires": "This field must equal 1, 7 or 365"})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"snippet": models.Snippet{ID: 2, Title: created.Title}})
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// The setupConfig() function points the client at a config file in a
+// temporary directory, and clears the environment variables which override
+// it. If server isn't empty, a config for it is saved first.
+func setupConfig(t *testing.T, server string) string {
+
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	t.Setenv("SNIPPET_CONFIG", path)
+	t.Setenv("SNIPPETBOX_URL", "")
+	t.Setenv("SNIPPETBOX_TOKEN", "")
+
+	if server != "" {
+		err := saveConfig(config{Server: server, Token: testToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func TestLogin(t *testing.T) {
+
+	ts := newTestServer(t, &snippetInput{})
+
+	t.Run("Valid token", func(t *testing.T) {
+
+		path := setupConfig(t, "")
+
+		var stdout, stderr bytes.Buffer
+
+		err := run([]string{"login", ts.URL + "/"}, strings.NewReader(testToken+"\n"), &stdout, &stderr)
+		assert.NilError(t, err)
+		assert.StringContains(t, stderr.String(), ts.URL+"/account/tokens")
+		assert.StringContains(t, stdout.String(), "as Alice")
+
+		info, err := os.Stat(path)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+		cfg, err := loadConfig()
+		assert.NilError(t, err)
+		assert.Equal(t, cfg, config{Server: ts.URL, Token: testToken})
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+
+		path := setupConfig(t, "")
+
+		var stdout, stderr bytes.Buffer
+
+		err := run([]string{"login", ts.URL}, strings.NewReader("sbx_nope\n"), &stdout, &stderr)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		assert.StringContains(t, err.Error(), "Unauthorized")
+
+		_, err = os.Stat(path)
+		assert.Equal(t, os.IsNotExist(err), true)
+	})
+}
+
+func TestCreate(t *testing.T) {
+
+	var created snippetInput
+	ts := newTestServer(t, &created)
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		want     snippetInput
+		wantOut  string
+		wantErr  string
+		noConfig bool
+	}{
+		{
+			name:    "From stdin",
+			args:    []string{"create", "--expires", "7d"},
+			stdin:   "\ndiff --git a/main.go b/main.go\n+fmt.Println()\n",
+			want:    snippetInput{Title: "diff --git a/main.go b/main.go", Content: "\ndiff --git a/main.go b/main.go\n+fmt.Println()\n", Expires: 7, Visibility: "public"},
+			wantOut: ts.URL + "/snippet/view/2\n",
+		},
+		{
+			name:    "With flags",
+			args:    []string{"create", "--title", "Build log", "--expires", "1y", "--visibility", "unlisted"},
+			stdin:   "ok",
+			want:    snippetInput{Title: "Build log", Content: "ok", Expires: 365, Visibility: "unlisted"},
+			wantOut: ts.URL + "/snippet/view/2\n",
+		},
+		{
+			name:    "Invalid expiry",
+			args:    []string{"create", "--expires", "soon"},
+			wantErr: `invalid expiry "soon"`,
+		},
+		{
+			name:    "Rejected by the server",
+			args:    []string{"create", "--expires", "2d"},
+			stdin:   "ok",
+			wantErr: "Unprocessable Entity\n  expires: This field must equal 1, 7 or 365",
+		},
+		{
+			name:     "Not logged in",
+			args:     []string{"create"},
+			stdin:    "ok",
+			wantErr:  "no server configured",
+			noConfig: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if tt.noConfig {
+				setupConfig(t, "")
+			} else {
+				setupConfig(t, ts.URL)
+			}
+
+			created = snippetInput{}
+
+			var stdout bytes.Buffer
+
+			err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &bytes.Buffer{})
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, created, tt.want)
+			assert.Equal(t, stdout.String(), tt.wantOut)
+		})
+	}
+}
+
+func TestCreateFromFile(t *testing.T) {
+
+	var created snippetInput
+	ts := newTestServer(t, &created)
+	setupConfig(t, ts.URL)
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+
+	err := os.WriteFile(path, []byte("Some notes"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+
+	err = run([]string{"create", path}, strings.NewReader(""), &stdout, &bytes.Buffer{})
+	assert.NilError(t, err)
+	assert.Equal(t, created, snippetInput{Title: "notes.txt", Content: "Some notes", Expires: 365, Visibility: "public"})
+}
+
+func TestGet(t *testing.T) {
+
+	ts := newTestServer(t, &snippetInput{})
+	setupConfig(t, ts.URL)
+
+	var stdout bytes.Buffer
+
+	err := run([]string{"get", "1"}, nil, &stdout, &bytes.Buffer{})
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), testSnippet.Content)
+
+	err = run([]string{"get", "99"}, nil, &stdout, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "Not Found")
+
+	err = run([]string{"get", "foo"}, nil, &stdout, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), `invalid snippet ID "foo"`)
+}
+
+func TestList(t *testing.T) {
+
+	ts := newTestServer(t, &snippetInput{})
+
+	// A config file isn't needed for anonymous requests, only a server.
+	setupConfig(t, "")
+	t.Setenv("SNIPPETBOX_URL", ts.URL)
+
+	var stdout bytes.Buffer
+
+	err := run([]string{"list"}, nil, &stdout, &bytes.Buffer{})
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "1\t2025-03-17\tAn old silent pond\n")
+}
+
+func TestParseExpires(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "1d", want: 1},
+		{in: "7d", want: 7},
+		{in: "7", want: 7},
+		{in: "1y", want: 365},
+		{in: "0d", wantErr: true},
+		{in: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+
+			got, err := parseExpires(tt.in)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// errUsage is returned when a command is called with the wrong arguments.
+var errUsage = errors.New(`usage:
+  snippetbox                         start the web server
+  snippetbox set-role <email> <role> set a user's role (user, moderator or admin)
+  snippetbox migrate up              apply the migrations the database doesn't have yet
+  snippetbox migrate down [<steps>]  undo the newest migration, or the given number of them
+  snippetbox migrate status          show the database's schema version
+  snippetbox migrate force <version> record the schema version without running anything`)
+
+// The runCommand() function runs one of the administrative commands that can
+// be given on the command line instead of starting the server. For example,
+// to promote the first admin in the Docker deployment:
+//
+//	docker exec snippetbox-app /app/snippetbox set-role alice@example.com admin
+func runCommand(ctx context.Context, args []string, users models.UserModelInterface, migrator models.MigratorInterface, stdout io.Writer) error {
+
+	switch args[0] {
+	case "set-role":
+		if len(args) != 3 {
+			return errUsage
+		}
+
+		email, role := args[1], args[2]
+
+		if !slices.Contains(models.Roles, role) {
+			return fmt.Errorf("unknown role %q, must be one of: %s", role, strings.Join(models.Roles, ", "))
+		}
+
+		err := users.SetRole(ctx, email, role)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				return fmt.Errorf("no user with email %q", email)
+			}
+			return err
+		}
+
+		fmt.Fprintf(stdout, "%s now has the %s role\n", email, role)
+		return nil
+	case "migrate":
+		return runMigrate(args[1:], migrator, stdout)
+	default:
+		return errUsage
+	}
+}
+
+// The runMigrate() function runs the migrate command, which changes or
+// reports the version of the database's schema. For a database whose tables
+// were created before migrations were added, "migrate force 1" records it as
+// being at the first version, so that later migrations can be applied.
+func runMigrate(args []string, migrator models.MigratorInterface, stdout io.Writer) error {
+
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(stdout, "applied %s\n", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		switch len(args) {
+		case 1:
+		case 2:
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errUsage
+			}
+			steps = n
+		default:
+			return errUsage
+		}
+
+		undone, err := migrator.Down(steps)
+		for _, migration := range undone {
+			fmt.Fprintf(stdout, "undid %s\n", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		if len(args) != 1 {
+			return errUsage
+		}
+	case "force":
+		if len(args) != 2 {
+			return errUsage
+		}
+
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+
+		err = migrator.Force(version)
+		if err != nil {
+			return err
+		}
+	default:
+		return errUsage
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	latest, err := migrator.Latest()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "schema version %d of %d\n", version, latest)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/mocks"
+)
+
+func TestRunCommand(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    bool
+		wantOutput string
+	}{
+		{
+			name:       "Promote admin",
+			args:       []string{"set-role", "alice@example.com", "admin"},
+			wantOutput: "alice@example.com now has the admin role\n",
+		},
+		{
+			name:    "Unknown user",
+			args:    []string{"set-role", "nobody@example.com", "admin"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown role",
+			args:    []string{"set-role", "alice@example.com", "superuser"},
+			wantErr: true,
+		},
+		{
+			name:    "Missing arguments",
+			args:    []string{"set-role", "alice@example.com"},
+			wantErr: true,
+		},
+		{
+			name:       "Migrate up",
+			args:       []string{"migrate", "up"},
+			wantOutput: "applied 0001_mock\napplied 0002_mock\nschema version 2 of 2\n",
+		},
+		{
+			name:       "Migrate status",
+			args:       []string{"migrate", "status"},
+			wantOutput: "schema version 0 of 2\n",
+		},
+		{
+			name:       "Migrate force",
+			args:       []string{"migrate", "force", "1"},
+			wantOutput: "schema version 1 of 2\n",
+		},
+		{
+			name:    "Migrate force unknown version",
+			args:    []string{"migrate", "force", "3"},
+			wantErr: true,
+		},
+		{
+			name:    "Migrate down zero steps",
+			args:    []string{"migrate", "down", "0"},
+			wantErr: true,
+		},
+		{
+			name:    "Migrate without subcommand",
+			args:    []string{"migrate"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"frobnicate"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			err := runCommand(t.Context(), tt.args, &mocks.UserModel{}, &mocks.Migrator{}, &stdout)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, stdout.String(), tt.wantOutput)
+		})
+	}
+}
+
+func TestRunCommandMigrateDown(t *testing.T) {
+
+	migrator := &mocks.Migrator{}
+
+	var stdout bytes.Buffer
+
+	err := runCommand(t.Context(), []string{"migrate", "up"}, &mocks.UserModel{}, migrator, &stdout)
+	assert.NilError(t, err)
+
+	stdout.Reset()
+
+	err = runCommand(t.Context(), []string{"migrate", "down"}, &mocks.UserModel{}, migrator, &stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "undid 0002_mock\nschema version 1 of 2\n")
+
+	stdout.Reset()
+
+	err = runCommand(t.Context(), []string{"migrate", "down", "5"}, &mocks.UserModel{}, migrator, &stdout)
+	assert.NilError(t, err)
+	assert.Equal(t, stdout.String(), "undid 0001_mock\nschema version 0 of 2\n")
+}
@@ -3,3 +3,11 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const userRoleContextKey = contextKey("userRole")
+
+const requestIDContextKey = contextKey("requestID")
+
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+const apiTokenContextKey = contextKey("apiToken")
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/High-la/snippetbox/internal/models"
 	"github.com/High-la/snippetbox/internal/validator"
@@ -14,7 +17,7 @@ import (
 // * application.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -26,8 +29,24 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
 
-	// Use the new render helper.
-	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
+	// Send an empty array rather than null in the JSON representation.
+	jsonSnippets := snippets
+	if jsonSnippets == nil {
+		jsonSnippets = []models.Snippet{}
+	}
+
+	// Use the respond helper, so that the latest snippets can also be fetched
+	// as JSON or as a plain text list.
+	app.respond(w, r, http.StatusOK, "home.tmpl.html", data, alternates{
+		JSON: map[string]any{"snippets": jsonSnippets},
+		Text: func() string {
+			var b strings.Builder
+			for _, s := range snippets {
+				fmt.Fprintf(&b, "%d\t%s\t%s\n", s.ID, humanDate(s.Created), s.Title)
+			}
+			return b.String()
+		},
+	})
 
 }
 
@@ -40,10 +59,10 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use the SnippetModel's Get() method to retrieve the data for a
-	// specific record based on its ID. if no matching record is found,
-	// return a 404 not found response.
-	snippet, err := app.snippets.Get(id)
+	// Use the viewableSnippet() helper to retrieve the data for a specific
+	// record based on its ID. if no matching record is found (or the user
+	// isn't allowed to see it), return a 404 not found response.
+	snippet, err := app.viewableSnippet(r.Context(), id, app.authenticatedUserID(r))
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
@@ -56,9 +75,33 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// And do the same thing again here...
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.IsOwner = snippet.OrgID == 0 && snippet.UserID != 0 && snippet.UserID == app.authenticatedUserID(r)
+	data.CanDelete = data.IsOwner
+
+	// An organisation's snippets can be deleted by the member who wrote them,
+	// and by the organisation's owners.
+	if snippet.OrgID != 0 {
+		org, err := app.orgs.Get(r.Context(), snippet.OrgID, app.authenticatedUserID(r))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		data.CanDelete = snippet.UserID == app.authenticatedUserID(r) || org.Role == models.OrgRoleOwner
+	}
+
+	// Public and unlisted snippets can be embedded on other sites, so the
+	// page advertises the oEmbed endpoint and the embed script for them.
+	if snippet.OrgID == 0 && snippet.Visibility != models.SnippetPrivate {
+		data.SnippetURL = app.absoluteURL(fmt.Sprintf("/snippet/view/%d", snippet.ID))
+		data.EmbedURL = app.absoluteURL(fmt.Sprintf("/snippet/embed/%d", snippet.ID))
+	}
 
-	// Use the new render helper.
-	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+	// Use the respond helper. The plain text representation is just the
+	// content, so that it can be piped straight into other commands.
+	app.respond(w, r, http.StatusOK, "view.tmpl.html", data, alternates{
+		JSON: map[string]any{"snippet": snippet},
+		Text: func() string { return snippet.Content },
+	})
 
 }
 
@@ -73,7 +116,8 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// 'initial' values for the form, here we set the initial value for the
 	// snippet expiray to 365 days.
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    365,
+		Visibility: models.SnippetPublic,
 	}
 
 	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
@@ -89,13 +133,60 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 // input with the name "title" in the Title field. The struct tag 'form:"-"'
 // tells the decoder to completely ignore a field during decoding.
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title      string `form:"title"`
+	Content    string `form:"content"`
+	Expires    int    `form:"expires"`
+	Visibility string `form:"visibility"`
 	// FieldErrors map[string]string
 	validator.Validator `form:"-"`
 }
 
+// keepExpiry is the number of days to keep a snippet for which means "don't
+// change it" when a snippet is edited, so that saving the edit form without
+// touching the expiry doesn't reset it.
+const keepExpiry = 0
+
+// The checkSnippet helper runs the validation checks for a new or updated
+// snippet, so that the HTML forms and the JSON API apply the same rules.
+// CheckField() will add the provided key and error message to the
+// FieldErrors map if the check does not evaluate to true. For example, in the
+// first line here we "check that the title is not blank". in the second, we
+// "check that the title has a max char length of 100" and so on. The edit form
+// can also leave the expiry time as it is, with keepExpiry.
+func checkSnippet(v *validator.Validator, title, content string, expires int, visibility string, editing bool) {
+	v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+	if editing {
+		v.CheckField(validator.PermittedValue(expires, keepExpiry, 1, 7, 365), "expires", "This field must equal 0, 1, 7 or 365")
+	} else {
+		v.CheckField(validator.PermittedValue(expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	}
+	v.CheckField(validator.PermittedValue(visibility, models.SnippetPublic, models.SnippetUnlisted, models.SnippetPrivate), "visibility", "This field must equal public, unlisted or private")
+}
+
+// The withSnippetChanges helper returns a copy of a snippet with a new or
+// updated title, content, expiry (in days from now) and visibility applied.
+// It lets handlers describe a snippet they've just saved, for example in a
+// webhook payload, without reading it back from the database. A zero Created
+// time is set to now, as it is for a new snippet.
+func withSnippetChanges(s models.Snippet, title, content string, expires int, visibility string) models.Snippet {
+
+	now := time.Now().UTC()
+
+	if s.Created.IsZero() {
+		s.Created = now
+	}
+	s.Title = title
+	s.Content = content
+	if expires != keepExpiry {
+		s.Expires = now.AddDate(0, 0, expires)
+	}
+	s.Visibility = visibility
+
+	return s
+}
+
 // Change the signature of the snippetCreatePost handler so it is defined as a method
 // agains * application.
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -114,16 +205,8 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	}
 
 	// Because the Validator struct is embedded by the snippetCreateForm struct
-	// we call CheckField() directly on it to execute our validation checks.
-	// CheckField() will add the provided key and error message to the
-	// FieldErrors map if the check does not evaluate to true. For example, in
-	// the first line here we "check that the form.Title field is not blank". in
-	// the second, we "check that the form.Title field has a max char
-	// length of 100" and so on.
-	form.CheckField(validator.NotBlank(form.Title), "title", "his field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	// we can pass it to checkSnippet() to execute our validation checks.
+	checkSnippet(&form.Validator, form.Title, form.Content, form.Expires, form.Visibility, false)
 
 	// Use the valid() method to see if any of the checks failed. If they did
 	// then re-render the template passing in the form in the same way as before.
@@ -134,14 +217,37 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Pass the data to the SnippetModel.Insert() method, receiving the
-	// ID for the new record back.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// If the user has switched to an organisation, the snippet belongs to
+	// the organisation and is only visible to its members.
+	org, err := app.currentOrg(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Pass the data to the SnippetModel.Insert() method (or the OrgModel's
+	// InsertSnippet() method), receiving the ID for the new record back.
+	var id int
+	if org.ID != 0 {
+		form.Visibility = models.SnippetPrivate
+		id, err = app.orgs.InsertSnippet(r.Context(), org.ID, app.authenticatedUserID(r), form.Title, form.Content, form.Expires)
+	} else {
+		id, err = app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, form.Expires, form.Visibility)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetCreated, map[string]any{"snippetID": id, "orgID": org.ID, "visibility": form.Visibility})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	snippet := withSnippetChanges(models.Snippet{ID: id, UserID: app.authenticatedUserID(r), OrgID: org.ID}, form.Title, form.Content, form.Expires, form.Visibility)
+	app.notifyWebhooks(r, models.WebhookSnippetCreated, snippet)
+
 	// Use the Put() method to add a string value ("Snippet successfully")
 	// created!") and the corresponding key ("flash") to the session data.
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
@@ -151,6 +257,197 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 }
 
+// The viewableSnippet helper returns a snippet if the given user (zero for an
+// anonymous visitor) is allowed to see it. Private snippets can only be viewed
+// by their owner, and organisation snippets by members. Everyone else gets
+// ErrNoRecord, the same as for a snippet which doesn't exist, so that we don't
+// leak the fact that it does.
+func (app *application) viewableSnippet(ctx context.Context, id, userID int) (models.Snippet, error) {
+
+	snippet, err := app.snippets.Get(ctx, id)
+
+	// Snippets owned by an organisation aren't returned by Get(), so if there
+	// is a logged in user, look for one of their organisations' snippets too.
+	if errors.Is(err, models.ErrNoRecord) && userID != 0 {
+		snippet, err = app.orgs.Snippet(ctx, id, userID)
+	}
+
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	if snippet.OrgID == 0 && snippet.Visibility == models.SnippetPrivate && (snippet.UserID == 0 || snippet.UserID != userID) {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// The editableSnippet helper returns a snippet if it belongs to the given
+// user, and ErrNoRecord (so as not to reveal that the snippet exists) if it
+// doesn't.
+func (app *application) editableSnippet(ctx context.Context, id, userID int) (models.Snippet, error) {
+
+	snippet, err := app.snippets.Get(ctx, id)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	if snippet.UserID == 0 || snippet.UserID != userID {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// The ownedSnippet helper fetches the snippet named by the {id} path value,
+// and checks that it belongs to the current user. If it doesn't exist or
+// belongs to someone else, it sends a 404 and returns false.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	snippet, err := app.editableSnippet(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// The snippetEdit handler displays the edit form for one of the current
+// user's snippets. It uses the same form struct as the create page.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Expires:    keepExpiry,
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl.html", data)
+}
+
+// The snippetEditPost handler updates one of the current user's snippets.
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkSnippet(&form.Validator, form.Title, form.Content, form.Expires, form.Visibility, true)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.snippets.Update(r.Context(), snippet.ID, form.Title, form.Content, form.Expires, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetUpdated, map[string]any{"snippetID": snippet.ID, "visibility": form.Visibility})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.notifyWebhooks(r, models.WebhookSnippetUpdated, withSnippetChanges(snippet, form.Title, form.Content, form.Expires, form.Visibility))
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// The snippetDeletePost handler deletes one of the current user's snippets, or
+// a snippet owned by one of their organisations. OrgModel.DeleteSnippet()
+// checks that they wrote it or own the organisation.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	snippet, err := app.viewableSnippet(r.Context(), id, userID)
+	if err == nil {
+		switch {
+		case snippet.OrgID != 0:
+			err = app.orgs.DeleteSnippet(r.Context(), snippet.ID, userID)
+		case snippet.UserID == 0 || snippet.UserID != userID:
+			err = models.ErrNoRecord
+		default:
+			err = app.snippets.Delete(r.Context(), snippet.ID)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrPermissionDenied):
+			app.clientError(w, http.StatusForbidden)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetDeleted, map[string]any{"snippetID": snippet.ID, "title": snippet.Title})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.notifyWebhooks(r, models.WebhookSnippetDeleted, snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+
+	if snippet.OrgID != 0 {
+		http.Redirect(w, r, fmt.Sprintf("/org/%d", snippet.OrgID), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
 // .....................................................
 // .....................................................
 // user Signup and Sign in section
@@ -159,6 +456,7 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
+	Handle              string `form:"handle"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
@@ -196,7 +494,21 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 4), "password", "This field must be at least 4 characters long")
+
+	// Check the password against the password policy.
+	err = app.checkPassword(&form.Validator, "password", form.Password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The handle is optional, but if one is given it must be valid and must
+	// not collide with the fixed routes under /user/.
+	form.Handle = strings.ToLower(strings.TrimSpace(form.Handle))
+	if form.Handle != "" {
+		form.CheckField(validator.Matches(form.Handle, validator.HandleRX), "handle", "This field must be 3-30 lowercase letters, digits, hyphens or underscores, starting with a letter")
+		form.CheckField(!validator.PermittedValue(form.Handle, reservedHandles...), "handle", "This handle is not available")
+	}
 
 	// If there are any errors, redisplay the signup form along with a 422
 	// status code.
@@ -209,19 +521,29 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to create a new user record in the database. If the email already
-	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	// or handle already exists then add an error message to the form and
+	// re-display it.
+	err = app.users.Insert(r.Context(), form.Name, form.Email, form.Handle, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		switch {
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
-		} else {
+		case errors.Is(err, models.ErrDuplicateHandle):
+			form.AddFieldError("handle", "This handle is not available")
+		default:
 			app.serverError(w, r, err)
+			return
 		}
 
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		return
+	}
+
+	err = app.audit(r, models.AuditUserSignup, map[string]any{"email": form.Email})
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -268,18 +590,33 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check wether the credentials are valid. If they're not, add a generic
-	// non-field error message and re-display the login page.
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	// non-field error message and re-display the login page. Suspended users
+	// get their own message.
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		var reason string
+
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
 			form.AddNonFieldError("Email or password is incorrect")
+			reason = "invalid_credentials"
+		case errors.Is(err, models.ErrUserSuspended):
+			form.AddNonFieldError("Your account has been suspended")
+			reason = "suspended"
+		default:
+			app.serverError(w, r, err)
+			return
+		}
 
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
-		} else {
+		err = app.audit(r, models.AuditUserLoginFailed, map[string]any{"email": form.Email, "reason": reason})
+		if err != nil {
 			app.serverError(w, r, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
@@ -292,11 +629,37 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the device metadata for the new session, so that the user can
+	// see it on their account page and revoke it later.
+	sessionID, err := app.userSessions.Insert(r.Context(), id, app.sessionManager.Token(r.Context()), r.UserAgent(), clientIP(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Add the ID of the current user to the session, so that they are now
-	// 'logged in'.
+	// 'logged in', along with the ID of the recorded session.
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "userSessionID", sessionID)
+
+	// The request context doesn't say that the user is authenticated yet, so
+	// pass the actor explicitly.
+	err = app.auditAs(r, id, models.AuditUserLogin, map[string]any{"sessionID": sessionID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// If the user was sent to the login page from a protected page, redirect
+	// them back there. The path is checked again here so that we never
+	// redirect to another site. Otherwise redirect them to the create snippet
+	// page.
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if isSafeRedirectPath(path) {
+		http.Redirect(w, r, path, http.StatusSeeOther)
+		return
+	}
 
-	// Redirect the user to the create snippet page.
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
@@ -311,9 +674,26 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Delete the record of this session so it no longer appears on the
+	// account page.
+	sessionID := app.sessionManager.GetString(r.Context(), "userSessionID")
+
+	err = app.userSessions.Revoke(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), sessionID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditUserLogout, map[string]any{"sessionID": sessionID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Remove the authenticatedUserID from the session data so that the user is
 	// 'logged out'.
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "userSessionID")
 
 	// Add a flash message to the session to confirm to the user that they've been
 	// logged out.
@@ -323,6 +703,450 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// reservedHandles lists the handles which can't be chosen at signup, because
+// they would be shadowed by other routes under /user/.
+var reservedHandles = []string{"signup", "login", "logout"}
+
+// profilePageSize is the number of snippets shown on each page of a profile.
+const profilePageSize = 10
+
+// The userByRef helper looks up a user from the {ref} path value of the
+// /user/{ref} routes, which can either be a numeric user ID or the user's
+// handle. It returns ErrNoRecord if there's no such user.
+func (app *application) userByRef(ctx context.Context, ref string) (models.User, error) {
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		if id < 1 {
+			return models.User{}, models.ErrNoRecord
+		}
+		return app.users.Get(ctx, id)
+	}
+
+	return app.users.GetByHandle(ctx, ref)
+}
+
+// The userProfile handler shows a user's public profile. The path value can
+// either be a numeric user ID or the user's handle.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+
+	user, err := app.userByRef(r.Context(), r.PathValue("ref"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Read the page number from the query string, defaulting to the first
+	// page if it's missing or invalid.
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Only public, non-expired snippets are returned by ForUser(), so private
+	// and unlisted snippets never appear here.
+	snippets, hasNext, err := app.snippets.ForUser(r.Context(), user.ID, page, profilePageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Snippets = snippets
+	data.Pagination = newPagination(page, hasNext)
+
+	app.render(w, r, http.StatusOK, "profile.tmpl.html", data)
+}
+
+// .....................................................
+// .....................................................
+// Account section
+
+// The accountView handler shows the user's details along with every session
+// that is currently signed in to their account.
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	sessions, err := app.userSessions.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.UserSessions = sessions
+	data.CurrentSessionID = app.sessionManager.GetString(r.Context(), "userSessionID")
+
+	app.render(w, r, http.StatusOK, "account.tmpl.html", data)
+}
+
+// The accountSessionRevokePost handler signs out a single session. If the
+// session being revoked is the current one, this behaves like a logout.
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := r.PostForm.Get("id")
+	if id == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if id == app.sessionManager.GetString(r.Context(), "userSessionID") {
+		app.userLogoutPost(w, r)
+		return
+	}
+
+	err = app.userSessions.Revoke(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session has been signed out.")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// The accountSessionRevokeOthersPost handler signs out every session apart
+// from the one making the request.
+func (app *application) accountSessionRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+
+	err := app.userSessions.RevokeAllExcept(r.Context(),
+		app.sessionManager.GetInt(r.Context(), "authenticatedUserID"),
+		app.sessionManager.GetString(r.Context(), "userSessionID"),
+	)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "All other sessions have been signed out.")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// Create a new accountPasswordForm struct.
+type accountPasswordForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+// The accountPasswordUpdate handler displays the change password form.
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordForm{}
+
+	app.render(w, r, http.StatusOK, "password.tmpl.html", data)
+}
+
+// The accountPasswordUpdatePost handler changes the current user's password,
+// once they have confirmed their current one.
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+
+	var form accountPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	err = app.checkPassword(&form.Validator, "newPassword", form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	_, err = app.users.Authenticate(r.Context(), user.Email, form.CurrentPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.UpdatePassword(r.Context(), userID, form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Sign out every other session, in case the password was changed because
+	// somebody else knew it.
+	err = app.userSessions.RevokeAllExcept(r.Context(), userID, app.sessionManager.GetString(r.Context(), "userSessionID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditUserPasswordChanged, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// Create a new apiTokenForm struct. Scopes holds the values of all the scope
+// checkboxes which were ticked.
+type apiTokenForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	validator.Validator `form:"-"`
+}
+
+// The renderAPITokens helper renders the API tokens page, listing the current
+// user's tokens. It's shared by the handlers below, as the create handler has
+// to render the page directly (rather than redirecting) to show the new token.
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, form apiTokenForm, newToken string) {
+
+	tokens, err := app.apiTokens.ForUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.APITokenScopes = models.APITokenScopes
+	data.NewAPIToken = newToken
+	data.Form = form
+
+	app.render(w, r, status, "tokens.tmpl.html", data)
+}
+
+// The accountTokens handler lists the current user's API tokens, and shows the
+// form for creating a new one.
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAPITokens(w, r, http.StatusOK, apiTokenForm{}, "")
+}
+
+// The accountTokenCreatePost handler creates a new API token. The token is
+// shown once, on the page returned by this handler, and can't be retrieved
+// again.
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+
+	var form apiTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Choose at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.APITokenScopes...), "scopes", "This field contains an unknown scope")
+	}
+
+	if !form.Valid() {
+		app.renderAPITokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	token, err := app.apiTokens.Insert(r.Context(), app.authenticatedUserID(r), form.Name, form.Scopes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditAPITokenCreated, map[string]any{"name": form.Name, "scopes": form.Scopes})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderAPITokens(w, r, http.StatusOK, apiTokenForm{}, token)
+}
+
+// Create a new apiTokenRevokeForm struct.
+type apiTokenRevokeForm struct {
+	ID int `form:"id"`
+}
+
+// The accountTokenRevokePost handler revokes one of the current user's API
+// tokens.
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+
+	var form apiTokenRevokeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.apiTokens.Revoke(r.Context(), app.authenticatedUserID(r), form.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditAPITokenRevoked, map[string]any{"tokenID": form.ID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked.")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// Create a new accountDeleteForm struct. The Snippets field says what to do
+// with the user's snippets: "delete" them or "anonymise" them.
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"`
+	validator.Validator `form:"-"`
+}
+
+// The accountDelete handler displays the account deletion form.
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{
+		Snippets: "delete",
+	}
+
+	app.render(w, r, http.StatusOK, "delete.tmpl.html", data)
+}
+
+// The accountDeletePost handler deletes the current user's account once they
+// have confirmed it with their password.
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Snippets, "delete", "anonymise"), "snippets", "This field must equal delete or anonymise")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Check the password by authenticating with the user's email address, in
+	// exactly the same way as the login form does.
+	_, err = app.users.Authenticate(r.Context(), user.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.Delete(r.Context(), userID, form.Snippets == "anonymise")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The user's snippets were deleted or anonymised behind the snippet
+	// cache's back, so it has to be emptied.
+	if app.snippetCache != nil {
+		app.snippetCache.Purge()
+	}
+
+	err = app.audit(r, models.AuditUserDeleted, map[string]any{"email": user.Email, "snippets": form.Snippets})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The user's sessions have all been purged, but this request still holds
+	// the session data in memory, so renew the token and clear it out as
+	// well before redirecting.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "userSessionID")
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 
 	w.Write([]byte("OK\n"))
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// .....................................................
+// .....................................................
+// Admin section. Every route here sits behind the admin middleware chain in
+// routes(), and every action which changes something is recorded in the
+// audit log.
+
+// adminListLimit is the number of users or snippets shown on the admin pages.
+const adminListLimit = 50
+
+// The adminDashboard handler shows some basic counters.
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+
+	var counts adminCounts
+	var err error
+
+	counts.Users, err = app.users.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	counts.LiveSnippets, err = app.snippets.CountLive(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	counts.Sessions, err = app.userSessions.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if app.snippetCache != nil {
+		stats := app.snippetCache.Stats()
+		counts.SnippetCache = &stats
+	}
+
+	data := app.newTemplateData(r)
+	data.AdminCounts = counts
+
+	app.render(w, r, http.StatusOK, "admin.tmpl.html", data)
+}
+
+// The adminUsers handler lists users, optionally filtered by the "q" query
+// string parameter.
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query().Get("q")
+
+	users, err := app.users.Search(r.Context(), query, adminListLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Query = query
+
+	app.render(w, r, http.StatusOK, "admin_users.tmpl.html", data)
+}
+
+// Create a new adminSuspendForm struct.
+type adminSuspendForm struct {
+	ID        int  `form:"id"`
+	Suspended bool `form:"suspended"`
+}
+
+// The adminUserSuspendPost handler suspends or unsuspends a user.
+func (app *application) adminUserSuspendPost(w http.ResponseWriter, r *http.Request) {
+
+	var form adminSuspendForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Don't let admins lock themselves out by accident.
+	if form.ID == app.authenticatedUserID(r) {
+		app.sessionManager.Put(r.Context(), "flash", "You can't suspend your own account.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	_, err = app.users.Get(r.Context(), form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.SetSuspended(r.Context(), form.ID, form.Suspended)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	eventType, flash := models.AuditAdminUserUnsuspended, "User unsuspended."
+	if form.Suspended {
+		eventType, flash = models.AuditAdminUserSuspended, "User suspended."
+	}
+
+	err = app.audit(r, eventType, map[string]any{"userID": form.ID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// The adminSnippets handler lists the most recent snippets, including private
+// and expired ones.
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+
+	snippets, err := app.snippets.Recent(r.Context(), adminListLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "admin_snippets.tmpl.html", data)
+}
+
+// Create a new adminSnippetDeleteForm struct.
+type adminSnippetDeleteForm struct {
+	ID int `form:"id"`
+}
+
+// The adminSnippetDeletePost handler deletes a snippet, whoever owns it.
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	var form adminSnippetDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), form.ID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Get() doesn't return expired snippets, so a missing record isn't an
+	// error here; Delete() will tell us if the snippet really doesn't exist.
+	err = app.snippets.Delete(r.Context(), form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditAdminSnippetDeleted, map[string]any{
+		"snippetID": form.ID,
+		"ownerID":   snippet.UserID,
+		"title":     snippet.Title,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The owner's webhooks are told about the deletion too, if the snippet
+	// hadn't already expired.
+	app.notifyWebhooks(r, models.WebhookSnippetDeleted, snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// The adminAudit handler shows the most recent entries in the audit log,
+// optionally filtered by the "user" (actor ID) and "type" query string
+// parameters.
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+
+	filter := models.AuditFilter{
+		Type:  r.URL.Query().Get("type"),
+		Limit: adminListLimit,
+	}
+
+	if user := r.URL.Query().Get("user"); user != "" {
+		id, err := strconv.Atoi(user)
+		if err != nil || id < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		filter.ActorID = id
+	}
+
+	if filter.Type != "" && !slices.Contains(models.AuditEventTypes, filter.Type) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := app.auditLog.List(r.Context(), filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.AuditEvents = events
+	data.AuditFilter = filter
+	data.AuditTypes = models.AuditEventTypes
+
+	app.render(w, r, http.StatusOK, "admin_audit.tmpl.html", data)
+}
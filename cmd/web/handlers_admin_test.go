@@ -0,0 +1,233 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/mocks"
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+func TestAdminAccess(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Dashboard as user",
+			email:    "alice@example.com",
+			urlPath:  "/admin",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Dashboard as admin",
+			email:    "admin@example.com",
+			urlPath:  "/admin",
+			wantCode: http.StatusOK,
+			wantBody: "Live snippets",
+		},
+		{
+			name:     "User search",
+			email:    "admin@example.com",
+			urlPath:  "/admin/users?q=alice",
+			wantCode: http.StatusOK,
+			wantBody: "alice@example.com",
+		},
+		{
+			name:     "Snippets",
+			email:    "admin@example.com",
+			urlPath:  "/admin/snippets",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminDashboardSnippetCache(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.snippetCache = models.NewCachedSnippetModel(app.snippets, 10, time.Minute)
+	app.snippets = app.snippetCache
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The first view misses the cache and the second one hits it.
+	for range 2 {
+		code, _, _ := ts.get(t, "/snippet/view/1")
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	ts.loginAs(t, "admin@example.com")
+
+	code, _, body := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "1 hits, 1 misses, 1 entries")
+}
+
+func TestAdminUserSuspendPost(t *testing.T) {
+
+	app := newTestApplication(t)
+	auditLog := app.auditLog.(*mocks.AuditModel)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin/users")
+	csrfToken := extractCSRFToken(t, body)
+
+	t.Run("Self", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("id", "2")
+		form.Add("suspended", "true")
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/admin/users/suspend", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		events, _ := auditLog.List(t.Context(), models.AuditFilter{Type: models.AuditAdminUserSuspended})
+		assert.Equal(t, len(events), 0)
+	})
+
+	t.Run("Other user", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("id", "1")
+		form.Add("suspended", "true")
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/admin/users/suspend", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/admin/users")
+
+		events, _ := auditLog.List(t.Context(), models.AuditFilter{Type: models.AuditAdminUserSuspended})
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].ActorID, 2)
+	})
+}
+
+func TestAdminSnippetDeletePost(t *testing.T) {
+
+	app := newTestApplication(t)
+	auditLog := app.auditLog.(*mocks.AuditModel)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin/snippets")
+
+	form := url.Values{}
+	form.Add("id", "1")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/admin/snippets/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/admin/snippets")
+
+	events, _ := auditLog.List(t.Context(), models.AuditFilter{Type: models.AuditAdminSnippetDeleted})
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].ActorID, 2)
+}
+
+func TestUserLoginSuspended(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "suspended@example.com")
+	form.Add("password", "1234")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Your account has been suspended")
+}
+
+func TestAdminAudit(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "All events",
+			urlPath:  "/admin/audit",
+			wantCode: http.StatusOK,
+			wantBody: models.AuditUserLogin,
+		},
+		{
+			name:     "Filtered",
+			urlPath:  "/admin/audit?user=2&type=user.login",
+			wantCode: http.StatusOK,
+			wantBody: models.AuditUserLogin,
+		},
+		{
+			name:     "No matches",
+			urlPath:  "/admin/audit?user=1",
+			wantCode: http.StatusOK,
+			wantBody: "No events found.",
+		},
+		{
+			name:     "Invalid user",
+			urlPath:  "/admin/audit?user=alice",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid type",
+			urlPath:  "/admin/audit?type=nope",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"github.com/High-la/snippetbox/internal/models"
+	"github.com/High-la/snippetbox/internal/validator"
+	"github.com/High-la/snippetbox/ui"
+)
+
+// .....................................................
+// .....................................................
+// API section. These handlers sit behind the api middleware chain in routes(),
+// and are authenticated with personal API tokens.
+
+// The apiSpec handler serves the OpenAPI document describing the API, from the
+// ui.Files embedded filesystem. TestOpenAPISpec checks that it lists every
+// API route registered in routes(), so remember to update it alongside them.
+func (app *application) apiSpec(w http.ResponseWriter, r *http.Request) {
+
+	spec, err := fs.ReadFile(ui.Files, "api/openapi.json")
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// The apiMe handler describes the user and token making the request, so that
+// scripts can check that their token works.
+func (app *application) apiMe(w http.ResponseWriter, r *http.Request) {
+
+	user, err := app.users.Get(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	token := r.Context().Value(apiTokenContextKey).(models.APIToken)
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{
+		"id":     user.ID,
+		"name":   user.Name,
+		"handle": user.Handle,
+		"token": map[string]any{
+			"name":   token.Name,
+			"scopes": token.Scopes,
+		},
+	})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// The apiNotFound handler sends a 404 for any path under /api/ which doesn't
+// match one of the API routes.
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiError(w, r, http.StatusNotFound, "the requested resource could not be found")
+}
+
+// The apiReaderID helper returns the ID of the user whose private snippets
+// can be read by this request: the token's owner, if the token has the
+// snippets:read scope, or zero otherwise.
+func (app *application) apiReaderID(r *http.Request) int {
+
+	token, ok := r.Context().Value(apiTokenContextKey).(models.APIToken)
+	if !ok || !token.HasScope(models.ScopeSnippetsRead) {
+		return 0
+	}
+
+	return token.UserID
+}
+
+// The apiSnippetID helper reads the {id} path value, sending a 404 and
+// returning false if it isn't a valid ID.
+func (app *application) apiSnippetID(w http.ResponseWriter, r *http.Request) (int, bool) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.apiNotFound(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// The apiSnippetInput type holds the JSON body for creating or updating a
+// snippet. Expires is a number of days, and defaults to 365 like the HTML
+// form does; Visibility defaults to public.
+type apiSnippetInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Expires    int    `json:"expires"`
+	Visibility string `json:"visibility"`
+}
+
+// The readSnippetInput helper decodes and validates the JSON body for a new
+// or updated snippet, sending the error response and returning false if
+// there's a problem.
+func (app *application) readSnippetInput(w http.ResponseWriter, r *http.Request) (apiSnippetInput, bool) {
+
+	input := apiSnippetInput{
+		Expires:    365,
+		Visibility: models.SnippetPublic,
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return apiSnippetInput{}, false
+	}
+
+	var v validator.Validator
+	checkSnippet(&v, input.Title, input.Content, input.Expires, input.Visibility, false)
+
+	if !v.Valid() {
+		app.apiValidationError(w, r, v)
+		return apiSnippetInput{}, false
+	}
+
+	return input, true
+}
+
+// The apiSnippetList handler returns the latest public snippets.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+
+	snippets, err := app.snippets.Latest(r.Context())
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// Always send an array, even if there aren't any snippets.
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"snippets": snippets})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// The apiSnippetGet handler returns a single snippet. Public and unlisted
+// snippets can be read without a token; private and organisation snippets
+// need a token with the snippets:read scope belonging to someone who can see
+// them.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := app.apiSnippetID(w, r)
+	if !ok {
+		return
+	}
+
+	snippet, err := app.viewableSnippet(r.Context(), id, app.apiReaderID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"snippet": snippet})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// The apiSnippetCreate handler creates a snippet owned by the token's user,
+// and returns it with a Location header pointing at the new resource.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+
+	input, ok := app.readSnippetInput(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	id, err := app.snippets.Insert(r.Context(), userID, input.Title, input.Content, input.Expires, input.Visibility)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetCreated, map[string]any{"snippetID": id, "visibility": input.Visibility})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// Build the response from the input rather than reading the snippet back
+	// from the database. The timestamps may be a moment out, but they're only
+	// shown to the nearest minute anywhere else.
+	snippet := withSnippetChanges(models.Snippet{ID: id, UserID: userID}, input.Title, input.Content, input.Expires, input.Visibility)
+
+	app.notifyWebhooks(r, models.WebhookSnippetCreated, snippet)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	err = app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": snippet})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// The apiSnippetUpdate handler replaces the title, content, expiry and
+// visibility of one of the token user's snippets.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := app.apiSnippetID(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	_, err := app.editableSnippet(r.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	input, ok := app.readSnippetInput(w, r)
+	if !ok {
+		return
+	}
+
+	err = app.snippets.Update(r.Context(), id, input.Title, input.Content, input.Expires, input.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetUpdated, map[string]any{"snippetID": id, "visibility": input.Visibility})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// Read the snippet back from the primary database, because a replica
+	// might not have the update yet. The API routes don't use the
+	// readYourWrites middleware, so nothing else would make sure of it.
+	snippet, err := app.snippets.Get(models.WithPrimary(r.Context()), id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.notifyWebhooks(r, models.WebhookSnippetUpdated, snippet)
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"snippet": snippet})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// The apiSnippetDelete handler deletes one of the token user's snippets.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := app.apiSnippetID(w, r)
+	if !ok {
+		return
+	}
+
+	snippet, err := app.editableSnippet(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditSnippetDeleted, map[string]any{"snippetID": id, "title": snippet.Title})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.notifyWebhooks(r, models.WebhookSnippetDeleted, snippet)
+
+	w.WriteHeader(http.StatusNoContent)
+}
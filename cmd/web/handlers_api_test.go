@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestAPIMe(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+		wantAuth      string
+		wantBody      string
+	}{
+		{
+			name:     "No token",
+			wantCode: http.StatusUnauthorized,
+			wantAuth: "Bearer",
+		},
+		{
+			name:          "Wrong scheme",
+			authorization: "Basic YWxpY2U6MTIzNA==",
+			wantCode:      http.StatusUnauthorized,
+			wantAuth:      `Bearer error="invalid_token"`,
+		},
+		{
+			name:          "Unknown token",
+			authorization: "Bearer sbx_nope",
+			wantCode:      http.StatusUnauthorized,
+			wantAuth:      `Bearer error="invalid_token"`,
+		},
+		{
+			name:          "Token for a deleted user",
+			authorization: "Bearer sbx_deleted_user",
+			wantCode:      http.StatusUnauthorized,
+			wantAuth:      `Bearer error="invalid_token"`,
+		},
+		{
+			name:          "Valid token",
+			authorization: "Bearer sbx_read",
+			wantCode:      http.StatusOK,
+			wantBody:      `"name":"Alice"`,
+		},
+		{
+			name:          "Lowercase scheme",
+			authorization: "bearer sbx_read",
+			wantCode:      http.StatusOK,
+			wantBody:      `"scopes":["snippets:read"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+
+			code, headers, body := ts.do(t, http.MethodGet, "/api/v1/me", header, nil)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("WWW-Authenticate"), tt.wantAuth)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPISnippetGet(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		urlPath       string
+		authorization string
+		wantCode      int
+		wantBody      string
+	}{
+		{
+			name:     "Public snippet",
+			urlPath:  "/api/v1/snippets/1",
+			wantCode: http.StatusOK,
+			wantBody: `"title":"An old silent pond"`,
+		},
+		{
+			name:     "Private snippet without a token",
+			urlPath:  "/api/v1/snippets/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Query timeout",
+			urlPath:  "/api/v1/snippets/98",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:          "Private snippet with the owner's token",
+			urlPath:       "/api/v1/snippets/3",
+			authorization: "Bearer sbx_read",
+			wantCode:      http.StatusOK,
+			wantBody:      `"visibility":"private"`,
+		},
+		{
+			name:          "Organisation snippet for a member",
+			urlPath:       "/api/v1/snippets/4",
+			authorization: "Bearer sbx_read",
+			wantCode:      http.StatusOK,
+			wantBody:      `"org_id":1`,
+		},
+		{
+			name:     "Organisation snippet without a token",
+			urlPath:  "/api/v1/snippets/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/api/v1/snippets/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/api/v1/snippets/foo",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown API path",
+			urlPath:  "/api/v1/nope",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+
+			code, headers, body := ts.do(t, http.MethodGet, tt.urlPath, header, nil)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusOK {
+				assert.Equal(t, headers.Get("Content-Type"), "application/json")
+			} else {
+				assert.Equal(t, headers.Get("Content-Type"), "application/problem+json")
+				assert.StringContains(t, body, fmt.Sprintf(`"status":%d`, tt.wantCode))
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPISnippetList(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.do(t, http.MethodGet, "/api/v1/snippets", nil, nil)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"snippets":[{"id":1,`)
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		wantCode      int
+		wantLocation  string
+		wantBody      string
+	}{
+		{
+			name:          "Valid submission",
+			authorization: "Bearer sbx_write",
+			body:          `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 7}`,
+			wantCode:      http.StatusCreated,
+			wantLocation:  "/api/v1/snippets/2",
+			wantBody:      `"visibility":"public"`,
+		},
+		{
+			name:          "Read-only token",
+			authorization: "Bearer sbx_read",
+			body:          `{"title": "O snail", "content": "Climb Mount Fuji"}`,
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:     "No token",
+			body:     `{"title": "O snail", "content": "Climb Mount Fuji"}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "Invalid fields",
+			authorization: "Bearer sbx_write",
+			body:          `{"title": "", "content": "Climb Mount Fuji", "expires": 2}`,
+			wantCode:      http.StatusUnprocessableEntity,
+			wantBody:      `"errors":{"expires":"This field must equal 1, 7 or 365","title":"This field cannot be blank"}`,
+		},
+		{
+			name:          "Unknown field",
+			authorization: "Bearer sbx_write",
+			body:          `{"title": "O snail", "content": "Climb Mount Fuji", "colour": "green"}`,
+			wantCode:      http.StatusBadRequest,
+			wantBody:      `body contains unknown field \"colour\"`,
+		},
+		{
+			name:          "Malformed JSON",
+			authorization: "Bearer sbx_write",
+			body:          `{"title": "O snail",`,
+			wantCode:      http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+
+			code, headers, body := ts.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(tt.body))
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		urlPath       string
+		authorization string
+		wantCode      int
+	}{
+		{
+			name:          "Own snippet",
+			urlPath:       "/api/v1/snippets/1",
+			authorization: "Bearer sbx_write",
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Read-only token",
+			urlPath:       "/api/v1/snippets/1",
+			authorization: "Bearer sbx_read",
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:          "Organisation snippet",
+			urlPath:       "/api/v1/snippets/4",
+			authorization: "Bearer sbx_write",
+			wantCode:      http.StatusNotFound,
+		},
+		{
+			name:          "Non-existent ID",
+			urlPath:       "/api/v1/snippets/99",
+			authorization: "Bearer sbx_write",
+			wantCode:      http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header := http.Header{}
+			header.Set("Authorization", tt.authorization)
+
+			body := strings.NewReader(`{"title": "O snail", "content": "Climb Mount Fuji", "visibility": "unlisted"}`)
+
+			code, _, _ := ts.do(t, http.MethodPut, tt.urlPath, header, body)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sbx_write")
+
+	code, _, body := ts.do(t, http.MethodDelete, "/api/v1/snippets/3", header, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+	assert.Equal(t, body, "")
+
+	code, _, _ = ts.do(t, http.MethodDelete, "/api/v1/snippets/99", header, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// .....................................................
+// .....................................................
+// Embeds section. Public and unlisted snippets can be shown on other sites,
+// either in an iframe pointing at /snippet/embed/{id}, or with a script tag
+// loading /snippet/embed/{id}.js, which adds the iframe itself. Sites which
+// support oEmbed can find the iframe markup through /oembed.
+
+// The default size of an embedded snippet, in pixels. oEmbed consumers can
+// ask for something smaller with maxwidth and maxheight.
+const (
+	embedWidth  = 600
+	embedHeight = 300
+)
+
+// The embeddableSnippet helper returns a snippet if it can be embedded, which
+// is the case for public and unlisted snippets. Embeds are shown on other
+// sites, where the viewer's session isn't available, so private and
+// organisation snippets can't be embedded.
+func (app *application) embeddableSnippet(ctx context.Context, id int) (models.Snippet, error) {
+	return app.viewableSnippet(ctx, id, 0)
+}
+
+// The snippetEmbed handler serves both the iframe page and the script which
+// loads it. They share a route because the servemux can't match a wildcard
+// followed by ".js" within a single path segment.
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+
+	ref, script := strings.CutSuffix(r.PathValue("id"), ".js")
+
+	id, err := strconv.Atoi(ref)
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.embeddableSnippet(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if script {
+		app.snippetEmbedScript(w, r, snippet)
+		return
+	}
+
+	// The embed routes don't use sessions, so the template data is built
+	// directly rather than with newTemplateData().
+	data := templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet:     snippet,
+	}
+
+	app.render(w, r, http.StatusOK, "embed.tmpl.html", data)
+}
+
+// embedScript is the loader sent by /snippet/embed/{id}.js. It inserts an
+// iframe showing the snippet just after the script tag which loaded it.
+const embedScript = `(function () {
+    var script = document.currentScript;
+    var iframe = document.createElement("iframe");
+    iframe.src = %s;
+    iframe.title = %s;
+    iframe.width = "%d";
+    iframe.height = "%d";
+    iframe.style.border = "0";
+    script.parentNode.insertBefore(iframe, script.nextSibling);
+})();
+`
+
+// The snippetEmbedScript helper sends the loader script for a snippet. The
+// strings are JSON encoded, which also escapes any "<" characters, so they're
+// safe to use as JavaScript string literals.
+func (app *application) snippetEmbedScript(w http.ResponseWriter, r *http.Request, snippet models.Snippet) {
+
+	src, err := json.Marshal(app.absoluteURL(fmt.Sprintf("/snippet/embed/%d", snippet.ID)))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	title, err := json.Marshal(snippet.Title)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	fmt.Fprintf(w, embedScript, src, title, embedWidth, embedHeight)
+}
+
+// The oEmbedResponse type is the JSON response for a rich oEmbed type, as
+// described at https://oembed.com.
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	AuthorURL    string `json:"author_url,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// The oEmbed handler describes how to embed the snippet at the URL given in
+// the query string. Only JSON responses are supported. As the oEmbed spec
+// requires, a request for any other format gets a 501 Not Implemented
+// response, and a URL which isn't an embeddable snippet on this site gets a
+// 404.
+func (app *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	u, err := url.Parse(query.Get("url"))
+	if err != nil || u.Host != app.baseURL.Host {
+		http.NotFound(w, r)
+		return
+	}
+
+	ref, ok := strings.CutPrefix(u.Path, "/snippet/view/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(ref)
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.embeddableSnippet(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Shrink the iframe if the consumer asked for a maximum size smaller
+	// than the default.
+	width, height := embedWidth, embedHeight
+	if n, err := strconv.Atoi(query.Get("maxwidth")); err == nil && n > 0 && n < width {
+		width = n
+	}
+	if n, err := strconv.Atoi(query.Get("maxheight")); err == nil && n > 0 && n < height {
+		height = n
+	}
+
+	src := app.absoluteURL(fmt.Sprintf("/snippet/embed/%d", snippet.ID))
+
+	res := oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Snippetbox",
+		ProviderURL:  app.absoluteURL("/"),
+		Title:        snippet.Title,
+		HTML: fmt.Sprintf(`<iframe src="%s" title="%s" width="%d" height="%d" style="border: 0"></iframe>`,
+			template.HTMLEscapeString(src), template.HTMLEscapeString(snippet.Title), width, height),
+		Width:  width,
+		Height: height,
+	}
+
+	// Credit the snippet's owner, if it still has one. It isn't worth failing
+	// the response if they can't be found.
+	if snippet.UserID != 0 {
+		user, err := app.users.Get(r.Context(), snippet.UserID)
+		if err == nil {
+			res.AuthorName = user.Name
+			res.AuthorURL = app.absoluteURL(fmt.Sprintf("/user/%d", user.ID))
+		}
+	}
+
+	js, err := json.Marshal(res)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
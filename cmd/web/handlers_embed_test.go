@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestSnippetEmbed(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Page",
+			urlPath:         "/snippet/embed/1",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        "An old silent pond...",
+		},
+		{
+			name:            "Script",
+			urlPath:         "/snippet/embed/1.js",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/javascript; charset=utf-8",
+			wantBody:        `iframe.src = "https://` + testHost + `/snippet/embed/1";`,
+		},
+		{
+			name:     "Private snippet",
+			urlPath:  "/snippet/embed/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private snippet script",
+			urlPath:  "/snippet/embed/3.js",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/embed/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/embed/foo.js",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), tt.wantContentType)
+			assert.StringContains(t, body, tt.wantBody)
+
+			// The embed routes can be framed by other sites, unlike the
+			// rest of the site.
+			assert.Equal(t, headers.Get("X-Frame-Options"), "")
+			assert.StringContains(t, headers.Get("Content-Security-Policy"), "frame-ancestors *")
+			assert.Equal(t, headers.Get("X-Content-Type-Options"), "nosniff")
+		})
+	}
+
+	t.Run("Other routes can't be framed", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/view/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("X-Frame-Options"), "deny")
+		assert.StringContains(t, body, "application/json+oembed")
+		assert.StringContains(t, body, "/snippet/embed/1.js")
+	})
+}
+
+func TestOEmbed(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		format     string
+		maxWidth   string
+		wantCode   int
+		wantWidth  int
+		wantAuthor string
+	}{
+		{
+			name:       "Valid",
+			url:        "https://" + testHost + "/snippet/view/1",
+			wantCode:   http.StatusOK,
+			wantWidth:  embedWidth,
+			wantAuthor: "Alice",
+		},
+		{
+			name:       "Smaller maxwidth",
+			url:        "https://" + testHost + "/snippet/view/1",
+			format:     "json",
+			maxWidth:   "320",
+			wantCode:   http.StatusOK,
+			wantWidth:  320,
+			wantAuthor: "Alice",
+		},
+		{
+			name:     "XML format",
+			url:      "https://" + testHost + "/snippet/view/1",
+			format:   "xml",
+			wantCode: http.StatusNotImplemented,
+		},
+		{
+			name:     "Private snippet",
+			url:      "https://" + testHost + "/snippet/view/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Another site",
+			url:      "https://example.com/snippet/view/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Not a snippet",
+			url:      "https://" + testHost + "/user/alice",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			query.Set("url", tt.url)
+			if tt.format != "" {
+				query.Set("format", tt.format)
+			}
+			if tt.maxWidth != "" {
+				query.Set("maxwidth", tt.maxWidth)
+			}
+
+			code, headers, body := ts.get(t, "/oembed?"+query.Encode())
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+			var res oEmbedResponse
+			err := json.Unmarshal([]byte(body), &res)
+			assert.NilError(t, err)
+
+			assert.Equal(t, res.Version, "1.0")
+			assert.Equal(t, res.Type, "rich")
+			assert.Equal(t, res.Title, "An old silent pond")
+			assert.Equal(t, res.Width, tt.wantWidth)
+			assert.Equal(t, res.AuthorName, tt.wantAuthor)
+			assert.StringContains(t, res.HTML, `<iframe src="https://`+testHost+`/snippet/embed/1"`)
+		})
+	}
+}
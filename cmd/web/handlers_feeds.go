@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// .....................................................
+// .....................................................
+// Feeds section. The latest public snippets, and each user's public snippets,
+// are available as Atom feeds (RFC 4287).
+
+// The atom types describe the parts of an Atom document which we use. Each
+// entry's expiry time is given with the age:expires element from the Atom
+// feed expiration extension, so that aggregators can drop it when the snippet
+// is no longer available.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	AgeNS   string      `xml:"xmlns:age,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Expires   string   `xml:"age:expires"`
+	Content   atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+const (
+	atomNamespaceAge = "http://purl.org/atompub/age/1.0"
+	mediaTypeAtom    = "application/atom+xml"
+)
+
+// atomTime formats a time as an RFC 3339 timestamp in UTC, as Atom requires.
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// The absoluteURL helper turns a path into an absolute URL on the site's
+// configured base URL. Atom IDs and links need to be absolute, because feeds
+// are read outside the context of the site. They're never built from the
+// request, which would give http:// links behind a TLS-terminating proxy and
+// let the Host header decide where they point.
+func (app *application) absoluteURL(path string) string {
+	return app.baseURL.Scheme + "://" + app.baseURL.Host + path
+}
+
+// The newAtomFeed helper builds a feed of snippets. The feed's updated time is
+// the creation time of the newest snippet, or since if there are none yet.
+// Snippets don't record when they were last edited, so each entry's updated
+// time is its creation time too.
+func (app *application) newAtomFeed(r *http.Request, title, alternate string, author atomAuthor, since time.Time, snippets []models.Snippet) atomFeed {
+
+	self := app.absoluteURL(r.URL.Path)
+
+	feed := atomFeed{
+		AgeNS: atomNamespaceAge,
+		ID:    self,
+		Title: title,
+		Links: []atomLink{
+			{Rel: "self", Type: mediaTypeAtom, Href: self},
+			{Rel: "alternate", Type: mediaTypeHTML, Href: app.absoluteURL(alternate)},
+		},
+		Author: author,
+	}
+
+	updated := since
+
+	for _, s := range snippets {
+		link := app.absoluteURL(fmt.Sprintf("/snippet/view/%d", s.ID))
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        link,
+			Title:     s.Title,
+			Link:      atomLink{Rel: "alternate", Type: mediaTypeHTML, Href: link},
+			Published: atomTime(s.Created),
+			Updated:   atomTime(s.Created),
+			Expires:   atomTime(s.Expires),
+			Content:   atomText{Type: "text", Body: s.Content},
+		})
+
+		if s.Created.After(updated) {
+			updated = s.Created
+		}
+	}
+
+	feed.Updated = atomTime(updated)
+
+	return feed
+}
+
+// The writeFeed helper encodes a feed and sends it, with support for
+// conditional GETs. The ETag is a hash of the encoded feed, so that it changes
+// whenever an entry is added, edited, deleted or expires. We deliberately
+// don't send Last-Modified: edits and expiries change the feed without
+// changing any timestamp in it, so If-Modified-Since can't be answered
+// reliably.
+func (app *application) writeFeed(w http.ResponseWriter, r *http.Request, feed atomFeed) {
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	err := xml.NewEncoder(&buf).Encode(feed)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	w.Header().Set("Content-Type", mediaTypeAtom+"; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// http.ServeContent() takes care of If-None-Match, and responds with 304
+	// Not Modified when it matches the ETag.
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// The feedLatest handler sends the latest public snippets, as listed on the
+// home page.
+func (app *application) feedLatest(w http.ResponseWriter, r *http.Request) {
+
+	snippets, err := app.snippets.Latest(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	feed := app.newAtomFeed(r, "Snippetbox", "/", atomAuthor{Name: "Snippetbox"}, time.Unix(0, 0), snippets)
+
+	app.writeFeed(w, r, feed)
+}
+
+// The feedUser handler sends the first page of a user's public snippets, as
+// listed on their profile.
+func (app *application) feedUser(w http.ResponseWriter, r *http.Request) {
+
+	ref := r.PathValue("ref")
+
+	user, err := app.userByRef(r.Context(), ref)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	snippets, _, err := app.snippets.ForUser(r.Context(), user.ID, 1, profilePageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	profile := "/user/" + ref
+	author := atomAuthor{Name: user.Name, URI: app.absoluteURL(profile)}
+
+	feed := app.newAtomFeed(r, user.Name+" - Snippetbox", profile, author, user.Created, snippets)
+
+	app.writeFeed(w, r, feed)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestFeeds(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantTitle string
+	}{
+		{
+			name:      "Latest",
+			urlPath:   "/feed.atom",
+			wantCode:  http.StatusOK,
+			wantTitle: "Snippetbox",
+		},
+		{
+			name:      "User by handle",
+			urlPath:   "/user/alice/feed.atom",
+			wantCode:  http.StatusOK,
+			wantTitle: "Alice - Snippetbox",
+		},
+		{
+			name:      "User by ID",
+			urlPath:   "/user/1/feed.atom",
+			wantCode:  http.StatusOK,
+			wantTitle: "Alice - Snippetbox",
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/user/bob/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), "application/atom+xml; charset=utf-8")
+
+			var feed atomFeed
+			err := xml.Unmarshal([]byte(body), &feed)
+			assert.NilError(t, err)
+
+			assert.Equal(t, feed.Title, tt.wantTitle)
+			assert.Equal(t, feed.ID, "https://"+testHost+tt.urlPath)
+			assert.Equal(t, len(feed.Entries), 1)
+
+			entry := feed.Entries[0]
+			assert.Equal(t, entry.Title, "An old silent pond")
+			assert.Equal(t, entry.Link.Href, "https://"+testHost+"/snippet/view/1")
+			assert.Equal(t, entry.Content.Body, "An old silent pond...")
+			assert.Equal(t, feed.Updated, entry.Updated)
+			assert.StringContains(t, body, "<age:expires>")
+		})
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/feed.atom")
+	assert.Equal(t, code, http.StatusOK)
+
+	etag := headers.Get("ETag")
+	assert.StringContains(t, etag, `"`)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{
+			name:        "Matching ETag",
+			ifNoneMatch: etag,
+			wantCode:    http.StatusNotModified,
+		},
+		{
+			name:        "Stale ETag",
+			ifNoneMatch: `"stale"`,
+			wantCode:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("If-None-Match", tt.ifNoneMatch)
+
+			code, _, _ := ts.do(t, http.MethodGet, "/feed.atom", header, nil)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
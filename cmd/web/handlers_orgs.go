@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/High-la/snippetbox/internal/models"
+	"github.com/High-la/snippetbox/internal/validator"
+)
+
+// .....................................................
+// .....................................................
+// Organisations section. The membership checks are all made by the OrgModel,
+// so the handlers here only need to turn its errors into the right responses.
+
+// The orgError helper sends the response for an error returned by the
+// OrgModel: a 404 for organisations the user isn't a member of, and a 403 for
+// actions their role doesn't allow.
+func (app *application) orgError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		http.NotFound(w, r)
+	case errors.Is(err, models.ErrPermissionDenied):
+		app.clientError(w, http.StatusForbidden)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// The currentOrg helper returns the organisation chosen with the org switcher,
+// or the zero Org if the user is working on their personal snippets. If the
+// user has since left the organisation, the choice is forgotten.
+func (app *application) currentOrg(r *http.Request) (models.Org, error) {
+
+	orgID := app.sessionManager.GetInt(r.Context(), "currentOrgID")
+	if orgID == 0 {
+		return models.Org{}, nil
+	}
+
+	org, err := app.orgs.Get(r.Context(), orgID, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Remove(r.Context(), "currentOrgID")
+			return models.Org{}, nil
+		}
+		return models.Org{}, err
+	}
+
+	return org, nil
+}
+
+// Create a new orgCreateForm struct.
+type orgCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// The orgCreate handler displays the form for creating an organisation.
+func (app *application) orgCreate(w http.ResponseWriter, r *http.Request) {
+
+	data := app.newTemplateData(r)
+	data.Form = orgCreateForm{}
+
+	app.render(w, r, http.StatusOK, "org_create.tmpl.html", data)
+}
+
+// The orgCreatePost handler creates an organisation, with the current user as
+// its owner, and switches to it.
+func (app *application) orgCreatePost(w http.ResponseWriter, r *http.Request) {
+
+	var form orgCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "org_create.tmpl.html", data)
+		return
+	}
+
+	id, err := app.orgs.Insert(r.Context(), form.Name, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditOrgCreated, map[string]any{"orgID": id, "name": form.Name})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "currentOrgID", id)
+	app.sessionManager.Put(r.Context(), "flash", "Organisation successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/org/%d", id), http.StatusSeeOther)
+}
+
+// The orgView handler shows an organisation's members and snippets.
+func (app *application) orgView(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	org, err := app.orgs.Get(r.Context(), id, userID)
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	members, err := app.orgs.Members(r.Context(), id, userID)
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	snippets, err := app.orgs.Snippets(r.Context(), id, userID)
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Org = org
+	data.OrgMembers = members
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "org.tmpl.html", data)
+}
+
+// Create a new orgInviteForm struct.
+type orgInviteForm struct {
+	Role string `form:"role"`
+}
+
+// The orgInvitePost handler creates an invitation link, and shows it to the
+// owner in a flash message so that they can pass it on.
+func (app *application) orgInvitePost(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form orgInviteForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Role, models.OrgRoleOwner, models.OrgRoleMember) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	token, err := app.orgs.CreateInvitation(r.Context(), id, app.authenticatedUserID(r), form.Role)
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditOrgMemberInvited, map[string]any{"orgID": id, "role": form.Role})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Share this link to invite somebody: /invite/%s (it can be used once, within 7 days)", token))
+
+	http.Redirect(w, r, fmt.Sprintf("/org/%d", id), http.StatusSeeOther)
+}
+
+// Create a new orgMemberRemoveForm struct.
+type orgMemberRemoveForm struct {
+	UserID int `form:"id"`
+}
+
+// The orgMemberRemovePost handler removes somebody from an organisation.
+func (app *application) orgMemberRemovePost(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form orgMemberRemoveForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil || form.UserID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.orgs.RemoveMember(r.Context(), id, app.authenticatedUserID(r), form.UserID)
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditOrgMemberRemoved, map[string]any{"orgID": id, "userID": form.UserID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Member removed.")
+
+	http.Redirect(w, r, fmt.Sprintf("/org/%d", id), http.StatusSeeOther)
+}
+
+// The orgJoin handler asks the user to confirm that they want to accept an
+// invitation. Accepting it has to be a POST, so that following a link can't
+// add somebody to an organisation by itself.
+func (app *application) orgJoin(w http.ResponseWriter, r *http.Request) {
+
+	data := app.newTemplateData(r)
+	data.InvitationToken = r.PathValue("token")
+
+	app.render(w, r, http.StatusOK, "org_join.tmpl.html", data)
+}
+
+// The orgJoinPost handler accepts an invitation and switches to the
+// organisation.
+func (app *application) orgJoinPost(w http.ResponseWriter, r *http.Request) {
+
+	orgID, err := app.orgs.AcceptInvitation(r.Context(), r.PathValue("token"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That invitation is invalid or has expired.")
+			http.Redirect(w, r, "/account", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditOrgMemberJoined, map[string]any{"orgID": orgID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "currentOrgID", orgID)
+	app.sessionManager.Put(r.Context(), "flash", "Welcome to the organisation!")
+
+	http.Redirect(w, r, fmt.Sprintf("/org/%d", orgID), http.StatusSeeOther)
+}
+
+// Create a new orgSwitchForm struct. An OrgID of zero switches back to the
+// user's personal snippets.
+type orgSwitchForm struct {
+	OrgID int `form:"org"`
+}
+
+// The orgSwitchPost handler changes the current organisation, which new
+// snippets are created in.
+func (app *application) orgSwitchPost(w http.ResponseWriter, r *http.Request) {
+
+	var form orgSwitchForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.OrgID < 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.OrgID == 0 {
+		app.sessionManager.Remove(r.Context(), "currentOrgID")
+		http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+		return
+	}
+
+	_, err = app.orgs.Get(r.Context(), form.OrgID, app.authenticatedUserID(r))
+	if err != nil {
+		app.orgError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "currentOrgID", form.OrgID)
+
+	http.Redirect(w, r, fmt.Sprintf("/org/%d", form.OrgID), http.StatusSeeOther)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+func TestOrgView(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Member",
+			email:    "alice@example.com",
+			urlPath:  "/org/1",
+			wantCode: http.StatusOK,
+			wantBody: "Team notes",
+		},
+		{
+			name:     "Not a member",
+			email:    "admin@example.com",
+			urlPath:  "/org/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Org snippet as member",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusOK,
+			wantBody: "Only Acme members can read this.",
+		},
+		{
+			name:     "Org snippet as non-member",
+			email:    "admin@example.com",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestOrgInvitePost(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		role     string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			email:    "alice@example.com",
+			role:     models.OrgRoleMember,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Invalid role",
+			email:    "alice@example.com",
+			role:     "admin",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Not a member",
+			email:    "admin@example.com",
+			role:     models.OrgRoleMember,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/account")
+
+			form := url.Values{}
+			form.Add("role", tt.role)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/org/1/invite", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusSeeOther {
+				_, _, body = ts.get(t, "/org/1")
+				assert.StringContains(t, body, "/invite/valid-token")
+			}
+		})
+	}
+}
+
+func TestOrgJoinPost(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		token        string
+		wantLocation string
+	}{
+		{
+			name:         "Valid invitation",
+			token:        "valid-token",
+			wantLocation: "/org/1",
+		},
+		{
+			name:         "Invalid invitation",
+			token:        "nope",
+			wantLocation: "/account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, "admin@example.com")
+
+			code, _, body := ts.get(t, "/invite/"+tt.token)
+			assert.Equal(t, code, http.StatusOK)
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, "/invite/"+tt.token, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestOrgSwitchPost(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	t.Run("Not a member", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("org", "99")
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/org/switch", form)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("org", "1")
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/org/switch", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/org/1")
+
+		_, _, body := ts.get(t, "/snippet/create")
+		assert.StringContains(t, body, "shared with the members of Acme")
+	})
+
+	t.Run("Create org snippet", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "O snail")
+		form.Add("content", "Climb Mount Fuji")
+		form.Add("expires", "7")
+		form.Add("visibility", models.SnippetPrivate)
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/snippet/create", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/5")
+	})
+
+	t.Run("Personal", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("org", "0")
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/org/switch", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/snippet/create")
+		assert.StringContains(t, body, "name='visibility' value='public'")
+	})
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/mocks"
+	"github.com/High-la/snippetbox/internal/models"
 )
 
 func TestPing(t *testing.T) {
@@ -50,6 +54,16 @@ func TestSnippetView(t *testing.T) {
 			urlPath:  "/snippet/view/2",
 			wantCode: http.StatusNotFound,
 		},
+		{
+			name:     "Private snippet",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Query timeout",
+			urlPath:  "/snippet/view/98",
+			wantCode: http.StatusServiceUnavailable,
+		},
 		{
 			name:     "Negative ID",
 			urlPath:  "/snippet/view/-1",
@@ -101,7 +115,7 @@ func TestUserSignup(t *testing.T) {
 
 	const (
 		validName     = "Bob"
-		validPassword = "1234"
+		validPassword = "pa$$word1234"
 		validEmail    = "test2@example.com"
 		// formTag       = "<form action='/user/signup' method='POST' novalidate>"
 		formTag = `<form action="/user/signup" method="POST" novalidate>`
@@ -111,6 +125,7 @@ func TestUserSignup(t *testing.T) {
 		name         string
 		userName     string
 		userEmail    string
+		userHandle   string
 		userPassword string
 		csrfToken    string
 		wantCode     int
@@ -177,6 +192,54 @@ func TestUserSignup(t *testing.T) {
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
 		},
+		{
+			name:         "Breached password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "password123",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid handle",
+			userName:     validName,
+			userEmail:    validEmail,
+			userHandle:   "9lives",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Reserved handle",
+			userName:     validName,
+			userEmail:    validEmail,
+			userHandle:   "login",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate handle",
+			userName:     validName,
+			userEmail:    validEmail,
+			userHandle:   "alice",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Valid handle",
+			userName:     validName,
+			userEmail:    validEmail,
+			userHandle:   "bob_99",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+		},
 		{
 			name:         "Duplicate email",
 			userName:     validName,
@@ -192,6 +255,7 @@ func TestUserSignup(t *testing.T) {
 			form := url.Values{}
 			form.Add("name", tt.userName)
 			form.Add("email", tt.userEmail)
+			form.Add("handle", tt.userHandle)
 			form.Add("password", tt.userPassword)
 			form.Add("csrf_token", tt.csrfToken)
 			code, _, body := ts.postForm(t, "/user/signup", form)
@@ -202,3 +266,712 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestAccountSessions(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users are sent to the login page.
+	code, headers, _ := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	// Once logged in, the account page lists the current session.
+	code, _, body := ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Sign out (this device)")
+
+	// Revoke the session behind the user's back, as though it had been
+	// signed out from another device. The scs token is still valid, but the
+	// authenticate middleware must now treat the request as anonymous.
+	sessions, err := app.userSessions.ForUser(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(sessions), 1)
+
+	err = app.userSessions.Revoke(t.Context(), 1, sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, headers, _ = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
+
+func TestAccountSessionRevokeOthers(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	// Record a session for the same user on another device.
+	otherID, err := app.userSessions.Insert(t.Context(), 1, "other-token", "Other Browser", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account")
+	assert.StringContains(t, body, "Other Browser")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/account/sessions/revoke-others", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account")
+
+	// The other session is gone, but the current one still works.
+	_, err = app.userSessions.Get(t.Context(), otherID)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	code, _, body = ts.get(t, "/account")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "Other Browser"), false)
+}
+
+func TestUserLoginRedirect(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		visitFirst   string
+		wantLocation string
+	}{
+		{
+			name:         "Direct login",
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:         "Bounced from protected page",
+			visitFirst:   "/account",
+			wantLocation: "/account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Use a fresh test server (and so a fresh cookie jar) for each
+			// case, so the remembered page doesn't leak between them.
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.visitFirst != "" {
+				code, headers, _ := ts.get(t, tt.visitFirst)
+				assert.Equal(t, code, http.StatusSeeOther)
+				assert.Equal(t, headers.Get("Location"), "/user/login")
+			}
+
+			_, _, body := ts.get(t, "/user/login")
+
+			form := url.Values{}
+			form.Add("email", "alice@example.com")
+			form.Add("password", "1234")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "By handle",
+			urlPath:  "/user/alice",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "By ID",
+			urlPath:  "/user/1",
+			wantCode: http.StatusOK,
+			wantBody: "@alice",
+		},
+		{
+			name:     "Empty later page",
+			urlPath:  "/user/alice?page=2",
+			wantCode: http.StatusOK,
+			wantBody: `?page=1`,
+		},
+		{
+			name:     "Non-existent handle",
+			urlPath:  "/user/bob",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/user/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Negative ID",
+			urlPath:  "/user/-1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Fixed routes take precedence",
+			urlPath:  "/user/login",
+			wantCode: http.StatusOK,
+			wantBody: `<form action="/user/login" method="POST" novalidate>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetViewPrivateOwner(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/view/3")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Only Alice can read this.")
+}
+
+func TestAccountDelete(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		password     string
+		snippets     string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:     "Wrong password",
+			password: "wrong",
+			snippets: "delete",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Empty password",
+			password: "",
+			snippets: "delete",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Invalid snippets option",
+			password: "1234",
+			snippets: "keep",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Delete",
+			password:     "1234",
+			snippets:     "delete",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/",
+		},
+		{
+			name:         "Anonymise",
+			password:     "1234",
+			snippets:     "anonymise",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/account/delete")
+
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("snippets", tt.snippets)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, "/account/delete", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			// After a successful deletion the client is no longer logged in.
+			if tt.wantCode == http.StatusSeeOther {
+				code, _, _ = ts.get(t, "/account")
+				assert.Equal(t, code, http.StatusSeeOther)
+			}
+		})
+	}
+}
+
+func TestAccountPasswordUpdate(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	const (
+		validCurrent = "1234"
+		validNew     = "a much longer password"
+	)
+
+	tests := []struct {
+		name            string
+		currentPassword string
+		newPassword     string
+		confirmation    string
+		wantCode        int
+		wantBody        string
+	}{
+		{
+			name:            "Valid",
+			currentPassword: validCurrent,
+			newPassword:     validNew,
+			confirmation:    validNew,
+			wantCode:        http.StatusSeeOther,
+		},
+		{
+			name:            "Wrong current password",
+			currentPassword: "wrong",
+			newPassword:     validNew,
+			confirmation:    validNew,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Current password is incorrect",
+		},
+		{
+			name:            "Mismatched confirmation",
+			currentPassword: validCurrent,
+			newPassword:     validNew,
+			confirmation:    "something else",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Passwords do not match",
+		},
+		{
+			name:            "Too short",
+			currentPassword: validCurrent,
+			newPassword:     "short",
+			confirmation:    "short",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "This field must be at least 8 characters long",
+		},
+		{
+			name:            "Breached",
+			currentPassword: validCurrent,
+			newPassword:     "password123",
+			confirmation:    "password123",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "This password has appeared in a data breach",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/account/password/update")
+
+			form := url.Values{}
+			form.Add("currentPassword", tt.currentPassword)
+			form.Add("newPassword", tt.newPassword)
+			form.Add("newPasswordConfirmation", tt.confirmation)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, "/account/password/update", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+
+	app := newTestApplication(t)
+	auditLog := app.auditLog.(*mocks.AuditModel)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "wrong")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/snippet/create")
+
+	form = url.Values{}
+	form.Add("title", "O snail")
+	form.Add("content", "Climb Mount Fuji")
+	form.Add("expires", "7")
+	form.Add("visibility", models.SnippetPublic)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/snippet/create", form)
+
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/logout", form)
+
+	assert.Equal(t, strings.Join(auditLog.Types(), " "), strings.Join([]string{
+		models.AuditUserLoginFailed,
+		models.AuditUserLogin,
+		models.AuditSnippetCreated,
+		models.AuditUserLogout,
+	}, " "))
+
+	failed := auditLog.Events[0]
+	assert.Equal(t, failed.ActorID, 0)
+	assert.Equal(t, failed.Details["email"], any("alice@example.com"))
+	assert.Equal(t, failed.IP, "127.0.0.1")
+	assert.Equal(t, len(failed.RequestID), 32)
+
+	for _, e := range auditLog.Events[1:] {
+		assert.Equal(t, e.ActorID, 1)
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		title        string
+		expires      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner",
+			email:        "alice@example.com",
+			urlPath:      "/snippet/edit/1",
+			title:        "A new title",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:         "Keep the expiry",
+			email:        "alice@example.com",
+			urlPath:      "/snippet/edit/1",
+			title:        "A new title",
+			expires:      "0",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:     "Invalid expiry",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "A new title",
+			expires:  "30",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Blank title",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Not the owner",
+			email:    "admin@example.com",
+			urlPath:  "/snippet/edit/1",
+			title:    "A new title",
+			expires:  "7",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent snippet",
+			email:    "alice@example.com",
+			urlPath:  "/snippet/edit/2",
+			title:    "A new title",
+			expires:  "7",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.loginAs(t, tt.email)
+
+			// Take the CSRF token from the create page, as the edit page
+			// isn't available to everyone.
+			_, _, body := ts.get(t, "/snippet/create")
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "Some content")
+			form.Add("expires", tt.expires)
+			form.Add("visibility", models.SnippetPublic)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetEditForm(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// The form starts out keeping the snippet's current expiry time, so that
+	// saving it without changes doesn't reset it.
+	code, _, body := ts.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "value='0' checked")
+	assert.StringContains(t, body, "Keep current")
+}
+
+func TestSnippetDelete(t *testing.T) {
+
+	app := newTestApplication(t)
+	auditLog := app.auditLog.(*mocks.AuditModel)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/snippet/edit/1")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/snippet/delete/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account")
+
+	events, _ := auditLog.List(t.Context(), models.AuditFilter{Type: models.AuditSnippetDeleted})
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Details["snippetID"], any(1))
+
+	// Organisation snippets are deleted through the organisation, and the
+	// user goes back to its page.
+	code, _, body = ts.get(t, "/snippet/view/4")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/snippet/delete/4")
+
+	code, headers, _ = ts.postForm(t, "/snippet/delete/4", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/org/1")
+
+	code, _, _ = ts.postForm(t, "/snippet/delete/2", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountTokens(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "CI")
+	assert.StringContains(t, body, "Never")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		tokName  string
+		scopes   []string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			tokName:  "Laptop",
+			scopes:   []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+			wantCode: http.StatusOK,
+			wantBody: "sbx_test_",
+		},
+		{
+			name:     "Blank name",
+			tokName:  "",
+			scopes:   []string{models.ScopeSnippetsRead},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "No scopes",
+			tokName:  "Laptop",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Choose at least one scope",
+		},
+		{
+			name:     "Unknown scope",
+			tokName:  "Laptop",
+			scopes:   []string{"admin"},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field contains an unknown scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokName)
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/tokens/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	t.Run("Revoke", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("id", "2")
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/account/tokens/revoke", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account/tokens")
+
+		header := http.Header{}
+		header.Set("Authorization", "Bearer sbx_write")
+
+		code, _, _ = ts.do(t, http.MethodGet, "/api/v1/me", header, nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Home as HTML",
+			urlPath:         "/",
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        "<title>Home - Snippetbox</title>",
+		},
+		{
+			name:            "Home as JSON",
+			urlPath:         "/",
+			accept:          "application/json",
+			wantContentType: "application/json",
+			wantBody:        `{"snippets":[{"id":1,"user_id":1,"title":"An old silent pond"`,
+		},
+		{
+			name:            "Home as text",
+			urlPath:         "/",
+			accept:          "text/plain",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "\tAn old silent pond",
+		},
+		{
+			name:            "Snippet as JSON",
+			urlPath:         "/snippet/view/1",
+			accept:          "application/json",
+			wantContentType: "application/json",
+			wantBody:        `{"snippet":{"id":1,`,
+		},
+		{
+			name:            "Snippet as text",
+			urlPath:         "/snippet/view/1",
+			accept:          "text/plain",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "An old silent pond...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header := http.Header{}
+			if tt.accept != "" {
+				header.Set("Accept", tt.accept)
+			}
+
+			code, headers, body := ts.do(t, http.MethodGet, tt.urlPath, header, nil)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, headers.Get("Content-Type"), tt.wantContentType)
+			assert.Equal(t, slices.Contains(headers.Values("Vary"), "Accept"), true)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	// The text representation of a snippet is exactly its content.
+	header := http.Header{}
+	header.Set("Accept", "text/plain")
+
+	_, _, body := ts.do(t, http.MethodGet, "/snippet/view/1", header, nil)
+	assert.Equal(t, body, "An old silent pond...")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/High-la/snippetbox/internal/models"
+	"github.com/High-la/snippetbox/internal/validator"
+)
+
+// .....................................................
+// .....................................................
+// Webhooks section. Users can register URLs which are sent a signed JSON
+// payload when their snippets change. The deliveries themselves are made in
+// the background by app.dispatcher.
+
+// The notifyWebhooks helper queues an event for the webhooks of the snippet's
+// owner. Snippets which belong to an organisation, or whose owner has been
+// deleted, don't have an owner to notify. The change which caused the event
+// has already been made by the time this is called, so an error here is
+// logged rather than failing the request. For the same reason, the events are
+// still recorded if the client goes away in the meantime.
+func (app *application) notifyWebhooks(r *http.Request, event string, snippet models.Snippet) {
+
+	if snippet.OrgID != 0 || snippet.UserID == 0 {
+		return
+	}
+
+	err := app.dispatcher.Notify(context.WithoutCancel(r.Context()), snippet.UserID, event, snippet)
+	if err != nil {
+		app.logger.Error("notifying webhooks", "event", event, "snippet", snippet.ID, "err", err, "request_id", requestID(r))
+	}
+}
+
+// Create a new webhookForm struct.
+type webhookForm struct {
+	URL                 string `form:"url"`
+	validator.Validator `form:"-"`
+}
+
+// The renderWebhooks helper renders the webhooks page, listing the current
+// user's webhooks and their most recent deliveries.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, form webhookForm) {
+
+	userID := app.authenticatedUserID(r)
+
+	webhooks, err := app.webhooks.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(r.Context(), userID, 50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = webhooks
+	data.WebhookDeliveries = deliveries
+	data.Form = form
+
+	app.render(w, r, status, "webhooks.tmpl.html", data)
+}
+
+// The accountWebhooks handler lists the current user's webhooks and the
+// delivery log, and shows the form for adding a webhook.
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, http.StatusOK, webhookForm{})
+}
+
+// The accountWebhookCreatePost handler registers a new webhook URL.
+func (app *application) accountWebhookCreatePost(w http.ResponseWriter, r *http.Request) {
+
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(form.URL)
+	validURL := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 255), "url", "This field cannot be more than 255 characters long")
+	form.CheckField(validURL, "url", "This field must be an http or https URL")
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	id, err := app.webhooks.Insert(r.Context(), app.authenticatedUserID(r), form.URL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.audit(r, models.AuditWebhookCreated, map[string]any{"webhookID": id, "url": form.URL})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook added.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// Create a new webhookDeleteForm struct.
+type webhookDeleteForm struct {
+	ID int `form:"id"`
+}
+
+// The accountWebhookDeletePost handler removes one of the current user's
+// webhooks.
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	var form webhookDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.webhooks.Delete(r.Context(), app.authenticatedUserID(r), form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.audit(r, models.AuditWebhookDeleted, map[string]any{"webhookID": form.ID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook removed.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
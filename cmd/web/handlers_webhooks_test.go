@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/mocks"
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+func TestAccountWebhooks(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/webhooks")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "https://example.com/hooks/snippets")
+	assert.StringContains(t, body, "mock-secret")
+	assert.StringContains(t, body, "Nothing has been sent yet.")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			url:      "https://ci.example.com/snippetbox",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Blank URL",
+			url:      "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Not an http URL",
+			url:      "ftp://example.com/",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be an http or https URL",
+		},
+		{
+			name:     "No host",
+			url:      "https:///path",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be an http or https URL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("url", tt.url)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/webhooks/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	_, _, body = ts.get(t, "/account/webhooks")
+	assert.StringContains(t, body, "https://ci.example.com/snippetbox")
+
+	t.Run("Delete", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("id", "1")
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/account/webhooks/delete", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account/webhooks")
+
+		code, _, _ = ts.postForm(t, "/account/webhooks/delete", form)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestWebhookEvents(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	webhookModel := app.webhooks.(*mocks.WebhookModel)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sbx_write")
+
+	code, _, _ := ts.do(t, http.MethodPost, "/api/v1/snippets", header, strings.NewReader(`{"title": "O snail", "content": "Climb Mount Fuji"}`))
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _, _ = ts.do(t, http.MethodPut, "/api/v1/snippets/1", header, strings.NewReader(`{"title": "O snail", "content": "Climb Mount Fuji"}`))
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.do(t, http.MethodDelete, "/api/v1/snippets/3", header, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	// The dispatcher isn't started in tests, so the deliveries are still
+	// pending.
+	deliveries, err := webhookModel.Deliveries(t.Context(), 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(deliveries), 3)
+	assert.Equal(t, deliveries[2].Event, models.WebhookSnippetCreated)
+	assert.Equal(t, deliveries[1].Event, models.WebhookSnippetUpdated)
+	assert.Equal(t, deliveries[0].Event, models.WebhookSnippetDeleted)
+	assert.Equal(t, deliveries[0].Status, models.DeliveryPending)
+
+	// Snippets created in an organisation don't have an owner to notify.
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("org", "1")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/org/switch", form)
+
+	form = url.Values{}
+	form.Add("title", "Team notes")
+	form.Add("content", "Only for Acme")
+	form.Add("expires", "7")
+	form.Add("visibility", models.SnippetPrivate)
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	deliveries, err = webhookModel.Deliveries(t.Context(), 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(deliveries), 3)
+}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/High-la/snippetbox/internal/models"
+	"github.com/High-la/snippetbox/internal/validator"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
 )
@@ -48,15 +57,36 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 // initialized with the current year. Note that we're not using the *http.Request
 // parameter here at the moment, but we will do later
 func (app *application) newTemplateData(r *http.Request) templateData {
-	return templateData{
+	data := templateData{
 		CurrentYear: time.Now().Year(),
 		// Add the flash message to the template data, if one exists.
 		Flash: app.sessionManager.PopString(r.Context(), "flash"),
 
 		// Add the authentication status to the template data.
 		IsAuthenticated: app.isAuthenticated(r),
+		UserRole:        app.userRole(r),
 		CSRFToken:       nosurf.Token(r), // Add the CSRF token.
 	}
+
+	// Authenticated users get the org switcher in the nav bar. It isn't worth
+	// failing the whole page if the organisations can't be loaded, so just
+	// log the error and leave the switcher out.
+	if data.IsAuthenticated {
+		orgs, err := app.orgs.ForUser(r.Context(), app.authenticatedUserID(r))
+		if err != nil {
+			app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", requestID(r))
+		}
+		data.Orgs = orgs
+
+		currentOrgID := app.sessionManager.GetInt(r.Context(), "currentOrgID")
+		for _, org := range orgs {
+			if org.ID == currentOrgID {
+				data.CurrentOrg = org
+			}
+		}
+	}
+
+	return data
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
@@ -93,10 +123,134 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 
 }
 
+// .....................................................
+// .....................................................
+// Content negotiation. Pages can offer JSON and plain text representations as
+// well as HTML, and the respond() helper picks between them using the Accept
+// header, so handlers don't need to look at it themselves.
+
+// Define an alternates type to hold the non-HTML representations of a page.
+// JSON is encoded with writeJSON(), and Text is called to get the plain text
+// body. Either can be left nil if the page doesn't offer that representation.
+type alternates struct {
+	JSON any
+	Text func() string
+}
+
+// The media types which respond() can send.
+const (
+	mediaTypeHTML = "text/html"
+	mediaTypeJSON = "application/json"
+	mediaTypeText = "text/plain"
+)
+
+// The respond helper sends a page in the representation that best matches the
+// request's Accept header: JSON or plain text if the page offers them and the
+// client prefers them, or HTML rendered with the render() helper otherwise.
+// Browsers, and clients which don't send an Accept header, get HTML.
+func (app *application) respond(w http.ResponseWriter, r *http.Request, status int, page string, data templateData, alt alternates) {
+
+	offers := []string{mediaTypeHTML}
+	if alt.JSON != nil {
+		offers = append(offers, mediaTypeJSON)
+	}
+	if alt.Text != nil {
+		offers = append(offers, mediaTypeText)
+	}
+
+	// The response depends on the Accept header, so tell caches not to serve
+	// one representation in place of another.
+	w.Header().Add("Vary", "Accept")
+
+	switch negotiate(r, offers...) {
+	case mediaTypeJSON:
+		err := app.writeJSON(w, status, alt.JSON)
+		if err != nil {
+			app.serverError(w, r, err)
+		}
+	case mediaTypeText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, alt.Text())
+	default:
+		app.render(w, r, status, page, data)
+	}
+}
+
+// The negotiate helper returns the offered media type that the request's
+// Accept header gives the highest quality value, using the most specific
+// matching media range for each offer (so "text/plain" beats "text/*", which
+// beats "*/*"). Ties go to the earlier offer, and if nothing offered is
+// acceptable the first offer is returned, rather than sending a 406.
+func negotiate(r *http.Request, offers ...string) string {
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ := offers[0], 0.0
+
+	for _, offer := range offers {
+		offerType, _, _ := strings.Cut(offer, "/")
+
+		q, specificity := 0.0, -1
+
+		for _, part := range strings.Split(accept, ",") {
+			params := strings.Split(part, ";")
+			mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+
+			var s int
+			switch {
+			case mediaRange == offer:
+				s = 2
+			case mediaRange == offerType+"/*":
+				s = 1
+			case mediaRange == "*/*":
+				s = 0
+			default:
+				continue
+			}
+
+			if s <= specificity {
+				continue
+			}
+
+			rangeQ := 1.0
+			for _, param := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "q") {
+					v, err := strconv.ParseFloat(value, 64)
+					if err == nil {
+						rangeQ = v
+					}
+				}
+			}
+
+			q, specificity = rangeQ, s
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
 // the serveError helper writes a log at Error level (including the request)
 // method and URI as attributes), then sends a generic 500 Internal Server Error
 // response to the user.
+//
+// Database queries which were cut short aren't bugs, so they're handled by
+// queryInterrupted() instead, without a stack trace.
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	if app.queryInterrupted(r, err) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
 	var (
 		method = r.Method
 		uri    = r.URL.RequestURI()
@@ -107,10 +261,163 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request, err
 	)
 
 	// Include the trace in the log entry.
-	app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", trace)
+	app.logger.Error(err.Error(), "method", method, "uri", uri, "request_id", requestID(r), "trace", trace)
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// The queryInterrupted helper logs errors from database queries which were
+// stopped before they finished, and reports whether err was one of them. The
+// caller then sends a 503 Service Unavailable response. A query which ran for
+// longer than the query timeout is logged at Warn level, as the database is
+// probably overloaded. A query which was canceled because the client went
+// away is only logged at Info level, and the client will never see the
+// response.
+func (app *application) queryInterrupted(r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, models.ErrTimeout):
+		app.logger.Warn(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", requestID(r))
+	case errors.Is(err, models.ErrCanceled):
+		app.logger.Info(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", requestID(r))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// .....................................................
+// .....................................................
+// API helpers. Errors from the API are sent as RFC 7807 "problem details"
+// JSON objects, rather than the plain text sent by clientError() and
+// serverError().
+
+// The problem type is an RFC 7807 problem details object. We don't define our
+// own problem types, so Type is always "about:blank" and Title is the
+// standard text for the status code. Errors holds the field errors from a
+// validator.Validator, keyed by field name.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// The writeProblem helper sends a problem details response.
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+
+	js, err := json.Marshal(p)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(js)
+}
+
+// The apiError helper sends a problem details response with the given status
+// code and explanation.
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	app.writeProblem(w, r, problem{Status: status, Detail: detail})
+}
+
+// The apiServerError helper is the API equivalent of serverError. It logs the
+// error in the same way, but sends a problem details response.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if app.queryInterrupted(r, err) {
+		w.Header().Set("Retry-After", "5")
+		app.apiError(w, r, http.StatusServiceUnavailable, "the request took too long to process, please try again later")
+		return
+	}
+
+	app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", requestID(r), "trace", string(debug.Stack()))
+	app.apiError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+// The apiValidationError helper sends a 422 response listing the field errors
+// from a validator.
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	app.writeProblem(w, r, problem{
+		Status: http.StatusUnprocessableEntity,
+		Detail: "the request contains invalid fields",
+		Errors: v.FieldErrors,
+	})
+}
+
+// The invalidTokenResponse helper sends a 401 Unauthorized response for a
+// request with a missing, malformed or revoked API token.
+func (app *application) invalidTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.apiError(w, r, http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+// maxJSONBytes is the largest request body that readJSON will accept.
+const maxJSONBytes = 1_048_576
+
+// The readJSON helper decodes a JSON request body into dst. Unknown fields and
+// trailing data are rejected, and the errors it returns are written so that
+// they can be shown to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains the wrong type for the %q field", typeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// The writeJSON helper encodes data as JSON and sends it with the given
+// status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) error {
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
 // The clientError helper sends a specific status code corresponding description
 // to the user. we'll use this later in the book to send responses like 400 "Bad Request"
 // when there's a problem with that the user sent.
@@ -129,3 +436,109 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// Return the role of the current user, or an empty string if the request is
+// not from an authenticated user.
+func (app *application) userRole(r *http.Request) string {
+
+	role, ok := r.Context().Value(userRoleContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return role
+}
+
+// The authenticatedUserID helper returns the ID of the current user, or zero if
+// the request is not from an authenticated user. It's read from the request
+// context, so that it works for requests authenticated with an API token as
+// well as with a session.
+func (app *application) authenticatedUserID(r *http.Request) int {
+
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// The checkPassword helper checks a new password against the password policy,
+// adding the first problem (if any) to the validator under the given key.
+func (app *application) checkPassword(v *validator.Validator, key, plaintext string) error {
+
+	problems, err := app.passwordPolicy.Check(plaintext)
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		v.AddFieldError(key, problem)
+	}
+
+	return nil
+}
+
+// The requestID helper returns the ID given to the request by the
+// assignRequestID middleware, or an empty string if there isn't one.
+func requestID(r *http.Request) string {
+
+	id, ok := r.Context().Value(requestIDContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
+
+// The audit helper records an event in the audit log, with the current user
+// as the actor.
+func (app *application) audit(r *http.Request, eventType string, details map[string]any) error {
+	return app.auditAs(r, app.authenticatedUserID(r), eventType, details)
+}
+
+// The auditAs helper records an event in the audit log with an explicit actor.
+// It's needed while logging in, when the request isn't authenticated yet.
+func (app *application) auditAs(r *http.Request, actorID int, eventType string, details map[string]any) error {
+	return app.auditLog.Insert(r.Context(), models.AuditEvent{
+		ActorID:   actorID,
+		Type:      eventType,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		RequestID: requestID(r),
+		Details:   details,
+	})
+}
+
+// The clientIP helper returns the IP address of the client, without the port
+// number that is included in r.RemoteAddr.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// The isSafeRedirectPath helper returns true if path is a relative path on
+// this site. Anything with a scheme or host, and protocol-relative paths like
+// "//example.com" or "/\example.com" which browsers treat as absolute, are
+// rejected to prevent open redirects. Browsers also strip tabs and newlines
+// from URLs, so any control characters are rejected too.
+func isSafeRedirectPath(path string) bool {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return false
+	}
+
+	if strings.ContainsFunc(path, unicode.IsControl) {
+		return false
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "" && u.Host == ""
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestIsSafeRedirectPath(t *testing.T) {
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{name: "Relative path", path: "/snippet/create", want: true},
+		{name: "Relative path with query", path: "/account?tab=sessions", want: true},
+		{name: "Empty", path: "", want: false},
+		{name: "Absolute URL", path: "https://example.com/", want: false},
+		{name: "Protocol-relative", path: "//example.com", want: false},
+		{name: "Backslash", path: "/\\example.com", want: false},
+		{name: "No leading slash", path: "example.com", want: false},
+		{name: "Tab", path: "/\t/example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, isSafeRedirectPath(tt.path), tt.want)
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+
+	offers := []string{mediaTypeHTML, mediaTypeJSON, mediaTypeText}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "No Accept header", accept: "", want: mediaTypeHTML},
+		{name: "Anything", accept: "*/*", want: mediaTypeHTML},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: mediaTypeHTML},
+		{name: "JSON", accept: "application/json", want: mediaTypeJSON},
+		{name: "Plain text", accept: "text/plain", want: mediaTypeText},
+		{name: "Case insensitive", accept: "Application/JSON", want: mediaTypeJSON},
+		{name: "Quality values", accept: "text/html;q=0.5, application/json", want: mediaTypeJSON},
+		{name: "Most specific range wins", accept: "text/*;q=0.9, text/html;q=0.1", want: mediaTypeText},
+		{name: "Zero quality", accept: "application/json;q=0, */*", want: mediaTypeHTML},
+		{name: "Nothing acceptable", accept: "image/png", want: mediaTypeHTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Accept", tt.accept)
+
+			assert.Equal(t, negotiate(r, offers...), tt.want)
+		})
+	}
+}
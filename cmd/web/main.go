@@ -4,33 +4,60 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/redisstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/joho/godotenv"
 
 	"github.com/High-la/snippetbox/internal/models"
+	"github.com/High-la/snippetbox/internal/password"
+	"github.com/High-la/snippetbox/internal/webhooks"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
 
 	"github.com/go-playground/form/v4"
+	"github.com/gomodule/redigo/redis"
 )
 
 type application struct {
 	logger         *slog.Logger
 	snippets       models.SnippetModelInterface // Use our new interface type.
+	snippetCache   *models.CachedSnippetModel   // Nil unless the snippet cache is enabled.
 	users          models.UserModelInterface    // Use our new interface type.
+	userSessions   models.UserSessionModelInterface
+	orgs           models.OrgModelInterface
+	apiTokens      models.APITokenModelInterface
+	webhooks       models.WebhookModelInterface
+	dispatcher     *webhooks.Dispatcher
+	passwordPolicy *password.Policy
+	auditLog       models.AuditModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	// The scheme and host used for absolute links, such as those in feeds.
+	baseURL *url.URL
+	// How long a session's reads stay on the primary database after it makes
+	// a change. Zero if there are no read replicas.
+	replicaStickiness time.Duration
 }
 
 func main() {
@@ -66,12 +93,18 @@ func main() {
 
 	fmt.Println("DSN:", dsn)
 
+	// The database is chosen by the DSN. A postgres:// URL selects
+	// PostgreSQL, a sqlite:// one selects a SQLite database file (which needs
+	// no database server at all), and anything else is treated as a MySQL
+	// DSN.
+	dialect := models.DialectFromDSN(dsn)
+
 	var db *sql.DB
 	var err error
 	maxAttempts := 10
 	for i := 1; i <= maxAttempts; i++ {
 
-		db, err = openDB(dsn)
+		db, err = openDB(dialect, dsn)
 		if err == nil {
 			break
 		}
@@ -85,6 +118,87 @@ func main() {
 	}
 	defer db.Close()
 
+	// Every query is given at most SNIPPETBOX_DB_QUERY_TIMEOUT to run (as well
+	// as being stopped if the client goes away), so that a slow query can't
+	// hold on to a connection long after the response has timed out. The
+	// default is well inside the server's 10 second WriteTimeout.
+	queryTimeout, err := envDuration("SNIPPETBOX_DB_QUERY_TIMEOUT", 5*time.Second)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+
+	// --------------------
+	// Migrations
+	// --------------------
+	// The schema is built up by the migrations embedded in the binary. If a
+	// command was given on the command line (such as "migrate up"), run it
+	// instead of starting the server.
+	migrator := &models.Migrator{DB: db, Dialect: dialect}
+
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), os.Args[1:], &models.UserModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}, migrator, os.Stdout)
+		if err != nil {
+			logger.Error(err.Error())
+			db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// With SNIPPETBOX_AUTO_MIGRATE=true, any new migrations are applied
+	// before the server starts. Otherwise they're applied with the migrate
+	// command, as part of the deployment. A SQLite database is a file with
+	// nobody else to set it up, so it's migrated automatically unless
+	// SNIPPETBOX_AUTO_MIGRATE=false.
+	autoMigrate, err := envBool("SNIPPETBOX_AUTO_MIGRATE", dialect == models.SQLite)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+
+	if autoMigrate {
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info("applied migration", "name", migration.Name)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			db.Close()
+			os.Exit(1)
+		}
+	}
+
+	// Refuse to start if the schema isn't the one this build expects,
+	// rather than failing on the first query which uses something that's
+	// missing.
+	err = migrator.Check()
+	if err != nil {
+		logger.Error(err.Error(), "hint", "run the migrate command, or set SNIPPETBOX_AUTO_MIGRATE=true")
+		db.Close()
+		os.Exit(1)
+	}
+
+	// --------------------
+	// Password hashing
+	// --------------------
+	passwordHasher, err := newPasswordHasher()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	passwordPolicy, err := newPasswordPolicy()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if passwordPolicy.Breached == nil {
+		logger.Warn("no breached password list configured, set SNIPPETBOX_BREACHED_PASSWORDS_FILE to enable the check")
+	}
+
 	// --------------------
 	// Templates & Forms
 	// --------------------
@@ -101,14 +215,103 @@ func main() {
 	// --------------------
 	// Sessions
 	// --------------------
-	// Use the scs.New() function to initialize a new session manager. then we
-	// configure it to use our MySQL database as the session store, and set a
-	// lifetime of 12 hours (so that sessions auto expires 12 hours)
+	// Use the newSessionManager() function to initialize a new session
+	// manager, which keeps sessions in the store chosen by
+	// SNIPPETBOX_SESSION_STORE. By default that's our database, and sessions
+	// have a lifetime of 12 hours (so that sessions auto expires 12 hours
 	// after first being created).
-	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
+	sessionManager, closeSessionStore, err := newSessionManager(dialect, db)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+	defer closeSessionStore()
+
+	// --------------------
+	// Webhooks
+	// --------------------
+	// The dispatcher sends webhook deliveries from a pool of background
+	// workers. It's started here, and drained during the graceful shutdown
+	// below. Deliveries to private addresses (localhost, the local network,
+	// cloud metadata endpoints) are refused, unless
+	// SNIPPETBOX_WEBHOOKS_ALLOW_PRIVATE_TARGETS=true for development.
+	allowPrivateTargets, err := envBool("SNIPPETBOX_WEBHOOKS_ALLOW_PRIVATE_TARGETS", false)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+
+	webhookModel := &models.WebhookModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+	dispatcher := webhooks.New(webhookModel, logger, webhooks.Config{AllowPrivateTargets: allowPrivateTargets})
+	dispatcher.Start()
+
+	// --------------------
+	// Read replicas
+	// --------------------
+	// With SNIPPETBOX_DB_REPLICA_DSNS set, snippet views, the home page and
+	// the admin user search read from the replicas, which are health checked
+	// every SNIPPETBOX_DB_REPLICA_CHECK_INTERVAL. Everything else uses the
+	// primary.
+	replicas, err := openReplicas(dialect, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+
+	// A replica may not have caught up with a change yet, so for
+	// SNIPPETBOX_DB_REPLICA_STICKINESS after a session makes a change (such
+	// as the redirect to a snippet which has just been created), its reads
+	// go to the primary. See the readYourWrites middleware.
+	var replicaStickiness time.Duration
+	if replicas != nil {
+		defer replicas.Close()
+
+		replicaStickiness, err = envDuration("SNIPPETBOX_DB_REPLICA_STICKINESS", 5*time.Second)
+		if err != nil {
+			logger.Error(err.Error())
+			db.Close()
+			os.Exit(1)
+		}
+	}
+
+	// --------------------
+	// Snippet cache
+	// --------------------
+	// With SNIPPETBOX_SNIPPET_CACHE=true, single snippets and the latest
+	// snippets are cached in memory, in front of the database. Each instance
+	// of the application has its own cache, which only sees the changes made
+	// through that instance, so with several instances a change can take up
+	// to SNIPPETBOX_SNIPPET_CACHE_TTL to show up everywhere.
+	var snippets models.SnippetModelInterface = &models.SnippetModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout, Replicas: replicas}
+
+	snippetCache, err := newSnippetCache(snippets)
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+	if snippetCache != nil {
+		snippets = snippetCache
+	}
+
+	// --------------------
+	// Base URL
+	// --------------------
+	// Absolute links (in feeds and embed code) are built from
+	// SNIPPETBOX_BASE_URL, the address users reach the site at. Behind a
+	// reverse proxy that's the proxy's public https:// address.
+	baseURL, err := newBaseURL()
+	if err != nil {
+		logger.Error(err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+	if os.Getenv("SNIPPETBOX_BASE_URL") == "" {
+		logger.Warn("no base URL configured, set SNIPPETBOX_BASE_URL to the site's public address", "default", baseURL.String())
+	}
 
 	// --------------------
 	// App
@@ -117,11 +320,22 @@ func main() {
 	// dependencies..
 	app := &application{
 		logger:         logger,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		snippets:       snippets,
+		snippetCache:   snippetCache,
+		users:          &models.UserModel{DB: db, Dialect: dialect, Hasher: passwordHasher, QueryTimeout: queryTimeout, Replicas: replicas},
+		userSessions:   &models.UserSessionModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout},
+		orgs:           &models.OrgModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout},
+		apiTokens:      &models.APITokenModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout},
+		webhooks:       webhookModel,
+		dispatcher:     dispatcher,
+		passwordPolicy: passwordPolicy,
+		auditLog:       &models.AuditModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		baseURL:        baseURL,
+
+		replicaStickiness: replicaStickiness,
 	}
 
 	// --------------------
@@ -233,15 +447,22 @@ func main() {
 		logger.Error("graceful shutdown failed", "err", err)
 	}
 
+	// Then let the webhook workers send the deliveries which are still
+	// queued, within what's left of the shutdown timeout. The HTTP server has
+	// stopped by now, so no new events can be queued.
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		logger.Error("webhook deliveries abandoned", "err", err)
+	}
+
 	logger.Info("server stopped cleanly")
 
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pool
-// for a given DSN.
-func openDB(dsn string) (*sql.DB, error) {
+// for a given DSN, using the driver for the dialect.
+func openDB(dialect models.Dialect, dsn string) (*sql.DB, error) {
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(dialect.DriverName(), dialect.DriverDSN(dsn))
 	if err != nil {
 		return nil, err
 	}
@@ -255,6 +476,379 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
+// The newSessionManager() function builds the session manager from the
+// environment. SNIPPETBOX_SESSION_STORE chooses where sessions are kept:
+//
+//   - "database" (the default) keeps them in the sessions table of our
+//     database.
+//   - "redis" keeps them in the Redis-protocol server at SNIPPETBOX_REDIS_URL.
+//     Only sessions go there; the snippet cache stays in each instance's
+//     memory.
+//   - "memory" keeps them in the memory of the process, so they're lost on
+//     restart and aren't shared between instances. It's meant for local runs.
+//
+// The returned function closes the store's connections, if it has any. The
+// user_sessions table is in the database whichever store is used, so
+// revoking a session works the same way with all of them.
+func newSessionManager(dialect models.Dialect, db *sql.DB) (*scs.SessionManager, func(), error) {
+
+	sessionManager := scs.New()
+
+	var err error
+
+	// The lifetime is the absolute limit on a session's age. The idle
+	// timeout (off by default) also ends a session which hasn't been used
+	// for that long.
+	sessionManager.Lifetime, err = envDuration("SNIPPETBOX_SESSION_LIFETIME", 12*time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessionManager.IdleTimeout, err = envDuration("SNIPPETBOX_SESSION_IDLE_TIMEOUT", 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if name := os.Getenv("SNIPPETBOX_SESSION_COOKIE_NAME"); name != "" {
+		sessionManager.Cookie.Name = name
+	}
+	sessionManager.Cookie.Domain = os.Getenv("SNIPPETBOX_SESSION_COOKIE_DOMAIN")
+
+	// Session cookies are only sent over HTTPS unless this is turned off,
+	// which is only useful behind a proxy that terminates TLS on the same
+	// machine.
+	sessionManager.Cookie.Secure, err = envBool("SNIPPETBOX_SESSION_COOKIE_SECURE", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// With persistent cookies turned off, sessions end when the browser is
+	// closed.
+	sessionManager.Cookie.Persist, err = envBool("SNIPPETBOX_SESSION_COOKIE_PERSIST", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch sameSite := os.Getenv("SNIPPETBOX_SESSION_COOKIE_SAMESITE"); sameSite {
+	case "", "lax":
+		sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		sessionManager.Cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		sessionManager.Cookie.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, nil, fmt.Errorf("SNIPPETBOX_SESSION_COOKIE_SAMESITE: unknown value %q", sameSite)
+	}
+
+	// The store is set up last, so that nothing needs closing if one of the
+	// settings above is invalid.
+	closeStore := func() {}
+
+	switch store := os.Getenv("SNIPPETBOX_SESSION_STORE"); store {
+	case "", "database":
+		sessionManager.Store = newSessionStore(dialect, db)
+	case "redis":
+		url := os.Getenv("SNIPPETBOX_REDIS_URL")
+		if url == "" {
+			url = "redis://localhost:6379/0"
+		}
+		pool := newRedisPool(url)
+		sessionManager.Store = redisstore.New(pool)
+		closeStore = func() { pool.Close() }
+	case "memory":
+		sessionManager.Store = memstore.New()
+	default:
+		return nil, nil, fmt.Errorf("SNIPPETBOX_SESSION_STORE: unknown session store %q", store)
+	}
+
+	return sessionManager, closeStore, nil
+}
+
+// The newRedisPool() function returns a connection pool for the Redis server
+// at the given URL, such as "redis://:password@localhost:6379/0". Connections
+// are checked before they're reused if they've been idle for more than a
+// minute.
+func newRedisPool(url string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialURLContext(ctx, url)
+		},
+		TestOnBorrow: func(c redis.Conn, lastUsed time.Time) error {
+			if time.Since(lastUsed) < time.Minute {
+				return nil
+			}
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// The newSessionStore() function returns the scs session store which keeps
+// sessions in the same database as everything else. Each store expects a
+// sessions table, which is created along with the rest of the schema.
+func newSessionStore(dialect models.Dialect, db *sql.DB) scs.Store {
+
+	switch dialect {
+	case models.PostgreSQL:
+		return postgresstore.New(db)
+	case models.SQLite:
+		return sqlite3store.New(db)
+	default:
+		return mysqlstore.New(db)
+	}
+}
+
+// The newPasswordHasher() function builds the password hasher from the
+// environment. Argon2id is the default; SNIPPETBOX_PASSWORD_HASHER=bcrypt
+// switches new hashes to bcrypt. Existing hashes in either format can always
+// be verified, and are upgraded on the user's next login.
+func newPasswordHasher() (password.Hasher, error) {
+
+	switch algorithm := os.Getenv("SNIPPETBOX_PASSWORD_HASHER"); algorithm {
+	case "", "argon2id":
+		hasher := password.DefaultArgon2id()
+
+		memory, err := envInt("SNIPPETBOX_ARGON2_MEMORY", int(hasher.Memory))
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := envInt("SNIPPETBOX_ARGON2_ITERATIONS", int(hasher.Iterations))
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envInt("SNIPPETBOX_ARGON2_PARALLELISM", int(hasher.Parallelism))
+		if err != nil {
+			return nil, err
+		}
+		if memory < 1 || iterations < 1 || parallelism < 1 || parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters: m=%d, t=%d, p=%d", memory, iterations, parallelism)
+		}
+
+		hasher.Memory = uint32(memory)
+		hasher.Iterations = uint32(iterations)
+		hasher.Parallelism = uint8(parallelism)
+
+		return hasher, nil
+	case "bcrypt":
+		cost, err := envInt("SNIPPETBOX_BCRYPT_COST", 12)
+		if err != nil {
+			return nil, err
+		}
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost: %d", cost)
+		}
+
+		return &password.Bcrypt{Cost: cost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", algorithm)
+	}
+}
+
+// The newPasswordPolicy() function builds the rules for new passwords from the
+// environment. SNIPPETBOX_PASSWORD_REQUIRE is a comma-separated list of
+// character classes (upper, lower, digit, symbol) that every password must
+// contain, and SNIPPETBOX_BREACHED_PASSWORDS_FILE is the path to a sorted
+// SHA-1 hash file, such as the Pwned Passwords "ordered by hash" download.
+func newPasswordPolicy() (*password.Policy, error) {
+
+	policy := password.DefaultPolicy()
+
+	var err error
+
+	policy.MinLength, err = envInt("SNIPPETBOX_PASSWORD_MIN_LENGTH", policy.MinLength)
+	if err != nil {
+		return nil, err
+	}
+	policy.MaxLength, err = envInt("SNIPPETBOX_PASSWORD_MAX_LENGTH", policy.MaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv("SNIPPETBOX_PASSWORD_REQUIRE"); v != "" {
+		for _, class := range strings.Split(v, ",") {
+			switch strings.TrimSpace(class) {
+			case "upper":
+				policy.RequireUpper = true
+			case "lower":
+				policy.RequireLower = true
+			case "digit":
+				policy.RequireDigit = true
+			case "symbol":
+				policy.RequireSymbol = true
+			default:
+				return nil, fmt.Errorf("SNIPPETBOX_PASSWORD_REQUIRE: unknown character class %q", class)
+			}
+		}
+	}
+
+	if path := os.Getenv("SNIPPETBOX_BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := password.OpenHashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// The openReplicas() function opens the read replicas listed in
+// SNIPPETBOX_DB_REPLICA_DSNS, separated by commas, and starts their health
+// checks. It returns nil if there aren't any. The replicas must be the same
+// kind of database as the primary. Unlike the primary, they aren't pinged
+// before the server starts: one which is down is just skipped until its
+// health check passes.
+func openReplicas(dialect models.Dialect, logger *slog.Logger) (*models.Replicas, error) {
+
+	var dsns []string
+	for dsn := range strings.SplitSeq(os.Getenv("SNIPPETBOX_DB_REPLICA_DSNS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	if len(dsns) == 0 {
+		return nil, nil
+	}
+
+	if dialect == models.SQLite {
+		return nil, errors.New("SNIPPETBOX_DB_REPLICA_DSNS: SQLite databases can't have read replicas")
+	}
+
+	interval, err := envDuration("SNIPPETBOX_DB_REPLICA_CHECK_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, dsn := range dsns {
+		if models.DialectFromDSN(dsn) != dialect {
+			return nil, fmt.Errorf("SNIPPETBOX_DB_REPLICA_DSNS: replica %d isn't a %s database", i+1, dialect)
+		}
+	}
+
+	var dbs []*sql.DB
+
+	for _, dsn := range dsns {
+		db, err := sql.Open(dialect.DriverName(), dialect.DriverDSN(dsn))
+		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+
+	replicas := models.NewReplicas(logger, dbs...)
+	replicas.Start(interval, min(interval, 2*time.Second))
+
+	return replicas, nil
+}
+
+// The newSnippetCache() function wraps the snippet model in a cache, if it's
+// enabled by SNIPPETBOX_SNIPPET_CACHE. It returns nil if it isn't.
+func newSnippetCache(snippets models.SnippetModelInterface) (*models.CachedSnippetModel, error) {
+
+	enabled, err := envBool("SNIPPETBOX_SNIPPET_CACHE", false)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	size, err := envInt("SNIPPETBOX_SNIPPET_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := envDuration("SNIPPETBOX_SNIPPET_CACHE_TTL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewCachedSnippetModel(snippets, size, ttl), nil
+}
+
+// The newBaseURL() function reads the site's public address from
+// SNIPPETBOX_BASE_URL, such as "https://snippetbox.example.com". It must be an
+// absolute http or https URL with no path. If it isn't set, the address of the
+// local server is used, which is only right when it's reached directly.
+func newBaseURL() (*url.URL, error) {
+
+	v := os.Getenv("SNIPPETBOX_BASE_URL")
+	if v == "" {
+		scheme := "http"
+		if os.Getenv("SNIPPETBOX_TLS_ENABLED") == "true" {
+			scheme = "https"
+		}
+
+		host := "localhost"
+		if port := os.Getenv("SNIPPETBOX_BIND_PORT"); port != "" {
+			host += ":" + port
+		}
+
+		return &url.URL{Scheme: scheme, Host: host}, nil
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, fmt.Errorf("SNIPPETBOX_BASE_URL: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("SNIPPETBOX_BASE_URL: %q must be an http or https URL with no path", v)
+	}
+
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}, nil
+}
+
+// The envBool() helper reads a boolean from the environment, such as "true"
+// or "false", returning the fallback value if the variable isn't set.
+func envBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+// The envInt() helper reads an integer from the environment, returning the
+// fallback value if the variable isn't set.
+func envInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+// The envDuration() helper reads a duration from the environment, such as
+// "500ms" or "3s", returning the fallback value if the variable isn't set.
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return d, nil
+}
+
 func fileExists(path string) bool {
 	if path == "" {
 		return false
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/models"
+	"github.com/alexedwards/scs/redisstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewSessionManager(t *testing.T) {
+
+	t.Run("Defaults", func(t *testing.T) {
+		// By default sessions are kept in the application's database, which
+		// is a SQLite file here so that the test doesn't need a server.
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sessions.db"))
+		assert.NilError(t, err)
+		defer db.Close()
+
+		sessionManager, closeStore, err := newSessionManager(models.SQLite, db)
+		assert.NilError(t, err)
+		defer closeStore()
+
+		_, ok := sessionManager.Store.(*sqlite3store.SQLite3Store)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, sessionManager.Lifetime, 12*time.Hour)
+		assert.Equal(t, sessionManager.IdleTimeout, time.Duration(0))
+		assert.Equal(t, sessionManager.Cookie.Name, "session")
+		assert.Equal(t, sessionManager.Cookie.Secure, true)
+		assert.Equal(t, sessionManager.Cookie.Persist, true)
+		assert.Equal(t, sessionManager.Cookie.SameSite, http.SameSiteLaxMode)
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		t.Setenv("SNIPPETBOX_SESSION_STORE", "memory")
+		t.Setenv("SNIPPETBOX_SESSION_LIFETIME", "24h")
+		t.Setenv("SNIPPETBOX_SESSION_IDLE_TIMEOUT", "30m")
+		t.Setenv("SNIPPETBOX_SESSION_COOKIE_NAME", "sb_session")
+		t.Setenv("SNIPPETBOX_SESSION_COOKIE_DOMAIN", "example.com")
+		t.Setenv("SNIPPETBOX_SESSION_COOKIE_SECURE", "false")
+		t.Setenv("SNIPPETBOX_SESSION_COOKIE_PERSIST", "false")
+		t.Setenv("SNIPPETBOX_SESSION_COOKIE_SAMESITE", "strict")
+
+		sessionManager, closeStore, err := newSessionManager(models.MySQL, nil)
+		assert.NilError(t, err)
+		defer closeStore()
+
+		_, ok := sessionManager.Store.(*memstore.MemStore)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, sessionManager.Lifetime, 24*time.Hour)
+		assert.Equal(t, sessionManager.IdleTimeout, 30*time.Minute)
+		assert.Equal(t, sessionManager.Cookie.Name, "sb_session")
+		assert.Equal(t, sessionManager.Cookie.Domain, "example.com")
+		assert.Equal(t, sessionManager.Cookie.Secure, false)
+		assert.Equal(t, sessionManager.Cookie.Persist, false)
+		assert.Equal(t, sessionManager.Cookie.SameSite, http.SameSiteStrictMode)
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		for key, value := range map[string]string{
+			"SNIPPETBOX_SESSION_STORE":           "cookie",
+			"SNIPPETBOX_SESSION_LIFETIME":        "12",
+			"SNIPPETBOX_SESSION_COOKIE_SECURE":   "maybe",
+			"SNIPPETBOX_SESSION_COOKIE_SAMESITE": "sometimes",
+		} {
+			t.Run(key, func(t *testing.T) {
+				t.Setenv(key, value)
+
+				_, _, err := newSessionManager(models.MySQL, nil)
+				if err == nil {
+					t.Errorf("got: nil; want an error for %s=%s", key, value)
+				}
+			})
+		}
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+
+		t.Setenv("SNIPPETBOX_SESSION_STORE", "redis")
+		t.Setenv("SNIPPETBOX_REDIS_URL", "redis://"+mr.Addr()+"/0")
+
+		sessionManager, closeStore, err := newSessionManager(models.MySQL, nil)
+		assert.NilError(t, err)
+		defer closeStore()
+
+		_, ok := sessionManager.Store.(*redisstore.RedisStore)
+		assert.Equal(t, ok, true)
+
+		// Put a value in a session with one request, and read it back with
+		// the next.
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /put", func(w http.ResponseWriter, r *http.Request) {
+			sessionManager.Put(r.Context(), "flash", "Hello from Redis")
+		})
+		mux.HandleFunc("GET /get", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, sessionManager.PopString(r.Context(), "flash"))
+		})
+
+		ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/put")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, len(mr.Keys()), 1)
+
+		code, _, body := ts.get(t, "/get")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "Hello from Redis")
+	})
+}
+
+func TestNewBaseURL(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Default",
+			env:  map[string]string{"SNIPPETBOX_BIND_PORT": "4000"},
+			want: "http://localhost:4000",
+		},
+		{
+			name: "Default with TLS",
+			env:  map[string]string{"SNIPPETBOX_BIND_PORT": "4000", "SNIPPETBOX_TLS_ENABLED": "true"},
+			want: "https://localhost:4000",
+		},
+		{
+			name: "Configured",
+			env:  map[string]string{"SNIPPETBOX_BASE_URL": "https://snippetbox.example.com/"},
+			want: "https://snippetbox.example.com",
+		},
+		{
+			name:    "Relative",
+			env:     map[string]string{"SNIPPETBOX_BASE_URL": "snippetbox.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "With a path",
+			env:     map[string]string{"SNIPPETBOX_BASE_URL": "https://example.com/snippetbox"},
+			wantErr: true,
+		},
+		{
+			name:    "Other scheme",
+			env:     map[string]string{"SNIPPETBOX_BASE_URL": "ftp://snippetbox.example.com"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SNIPPETBOX_BASE_URL", "SNIPPETBOX_BIND_PORT", "SNIPPETBOX_TLS_ENABLED"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			u, err := newBaseURL()
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, u.String(), tt.want)
+		})
+	}
+}
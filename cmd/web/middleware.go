@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/High-la/snippetbox/internal/models"
 	"github.com/justinas/nosurf"
 )
 
@@ -12,17 +20,101 @@ func commonHeaders(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
-		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "deny")
-		w.Header().Set("X-XSS-Protection", "0")
-		w.Header().Set("Server", "Go")
+		defaultHeaderPolicy.apply(w.Header())
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// The headerPolicy type holds the security headers sent with a response,
+// keyed by header name. An empty value means that the header isn't sent at
+// all.
+type headerPolicy map[string]string
+
+// defaultCSP is the Content-Security-Policy used by most of the site.
+const defaultCSP = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
+// defaultHeaderPolicy is sent with every response by commonHeaders. Routes
+// which need something different use withHeaderPolicy() to change it.
+var defaultHeaderPolicy = headerPolicy{
+	"Content-Security-Policy": defaultCSP,
+	"Referrer-Policy":         "origin-when-cross-origin",
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "deny",
+	"X-XSS-Protection":        "0",
+	"Server":                  "Go",
+}
+
+// embedHeaderPolicy is used by the embed routes, which are meant to be shown
+// in iframes on other sites, so framing is allowed from anywhere.
+var embedHeaderPolicy = defaultHeaderPolicy.with(headerPolicy{
+	"Content-Security-Policy": defaultCSP + "; frame-ancestors *",
+	"X-Frame-Options":         "",
+})
+
+// The with() method returns a copy of the policy with the given headers
+// replaced.
+func (p headerPolicy) with(changes headerPolicy) headerPolicy {
+
+	policy := make(headerPolicy, len(p)+len(changes))
+	maps.Copy(policy, p)
+	maps.Copy(policy, changes)
+
+	return policy
+}
+
+// The apply() method sets the policy's headers, and removes any which the
+// policy leaves empty.
+func (p headerPolicy) apply(h http.Header) {
+
+	for name, value := range p {
+		if value == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, value)
+		}
+	}
+}
+
+// The withHeaderPolicy middleware replaces the headers set by commonHeaders
+// with those of another policy, for the routes it's used on. As commonHeaders
+// is part of the standard chain, it has always run first.
+func withHeaderPolicy(p headerPolicy) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			p.apply(w.Header())
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The assignRequestID middleware gives every request a random ID, which is
+// added to the request context and sent back in the X-Request-ID header, so
+// that log lines and audit events can be tied to a single request. Any
+// X-Request-ID header sent by the client is ignored, as it can't be trusted.
+func assignRequestID(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b := make([]byte, 16)
+		_, err := rand.Read(b)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		id := hex.EncodeToString(b)
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +126,7 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 			uri    = r.URL.RequestURI()
 		)
 
-		app.logger.Info("received request", "ip", ip, "proto", proto, "method", method, "uri", uri)
+		app.logger.Info("received request", "ip", ip, "proto", proto, "method", method, "uri", uri, "request_id", requestID(r))
 
 		next.ServeHTTP(w, r)
 	})
@@ -69,6 +161,13 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		// return from the middleware chain so that no subsequent handlers in
 		// the chain are executed.
 		if !app.isAuthenticated(r) {
+			// Remember the page the user was trying to reach, so that we can
+			// send them back to it after they login. Only GET requests are
+			// remembered, as replaying a form submission makes no sense.
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
+
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
@@ -83,6 +182,28 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// The requireRole middleware only lets through authenticated users with one
+// of the given roles. It returns a function with the standard middleware
+// signature, so it can be appended to an alice chain like any other, for
+// example: protected.Append(app.requireRole(models.RoleAdmin)).
+func (app *application) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.isAuthenticated(r) {
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+				return
+			}
+
+			if !slices.Contains(roles, app.userRole(r)) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Create a NoSurf middleware function which uses a customized CSRF cookie with
 // the Secure, Path and HttpOnly attributes set.
 func noSurf(next http.Handler) http.Handler {
@@ -95,6 +216,34 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// The readYourWrites middleware keeps a session's reads on the primary
+// database for a short while after it makes a change, so that the change
+// can't seem to disappear because a read replica hasn't caught up with it
+// yet. Any request which isn't a GET or HEAD is treated as a change. The
+// time is kept in the session, rather than just in the request, because the
+// read usually comes from the next request, after a redirect. It does
+// nothing if there are no read replicas.
+func (app *application) readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.replicaStickiness <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The time is stored in Unix milliseconds, as the session store's
+		// gob encoding would need time.Time registering.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.sessionManager.Put(r.Context(), "primaryUntil", time.Now().Add(app.replicaStickiness).UnixMilli())
+		}
+
+		if time.Now().UnixMilli() < app.sessionManager.GetInt64(r.Context(), "primaryUntil") {
+			r = r.WithContext(models.WithPrimary(r.Context()))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -109,20 +258,49 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// Otherwise, we check to see if a user with that ID exists in our
-		// database.
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		// Check that the recorded session still exists. If it has been revoked
+		// from the account page (or never existed) we treat the request as
+		// anonymous, even though the scs token itself is still valid.
+		session, err := app.userSessions.Get(r.Context(), app.sessionManager.GetString(r.Context(), "userSessionID"))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, r, err)
 			return
 		}
 
-		// If a matching user is found, we know that the request is
-		// coming from an authenticated user who exists in our database. we
-		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
-		if exists {
+		if err != nil || session.UserID != id {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			app.sessionManager.Remove(r.Context(), "userSessionID")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Update the last seen time, but no more than once a minute so that we
+		// don't write to the database on every request.
+		if time.Since(session.LastSeen) > time.Minute {
+			err = app.userSessions.Touch(r.Context(), session.ID)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
+		// Otherwise, we fetch the user with that ID from our database, which
+		// also gives us their current role.
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// If a matching user is found and they haven't been suspended, we know
+		// that the request is coming from an authenticated user who exists in
+		// our database. we create a new copy of the request (with an
+		// isAuthenticatedContextKey value of true and the user's role in the
+		// request context) and assign it to r.
+		if err == nil && !user.Suspended {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, user.ID)
+			ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
 			r = r.WithContext(ctx)
 		}
 
@@ -130,3 +308,85 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// The authenticateToken middleware is the API equivalent of authenticate. It
+// checks the personal API token in an "Authorization: Bearer <token>" header
+// and, if it's valid, adds the same values to the request context as
+// authenticate does, along with the token itself so that its scopes can be
+// checked. API routes don't use cookies, so they don't need CSRF protection.
+// Requests without an Authorization header carry on anonymously, but an
+// invalid token is always rejected, so that clients find out straight away.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, plaintext, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || plaintext == "" {
+			app.invalidTokenResponse(w, r)
+			return
+		}
+
+		token, err := app.apiTokens.Authenticate(r.Context(), plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidTokenResponse(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		// Tokens stop working while their owner is suspended.
+		user, err := app.users.Get(r.Context(), token.UserID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.apiServerError(w, r, err)
+			return
+		}
+
+		if err != nil || user.Suspended {
+			app.invalidTokenResponse(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, user.ID)
+		ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
+		ctx = context.WithValue(ctx, apiTokenContextKey, token)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The requireScope middleware is the API equivalent of requireAuthentication.
+// It rejects requests which weren't authenticated with an API token, or whose
+// token hasn't been granted all of the given scopes.
+func (app *application) requireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(apiTokenContextKey).(models.APIToken)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiError(w, r, http.StatusUnauthorized, "you must be authenticated with an API token to access this resource")
+				return
+			}
+
+			for _, scope := range scopes {
+				if !token.HasScope(scope) {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+					app.apiError(w, r, http.StatusForbidden, fmt.Sprintf("your API token needs the %s scope to access this resource", scope))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/models"
 )
 
 func TestCommonHeaders(t *testing.T) {
@@ -81,3 +85,166 @@ func TestCommonHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRequireRole(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name          string
+		authenticated bool
+		role          string
+		wantCode      int
+	}{
+		{
+			name:     "Anonymous",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:          "User",
+			authenticated: true,
+			role:          models.RoleUser,
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:          "Moderator",
+			authenticated: true,
+			role:          models.RoleModerator,
+			wantCode:      http.StatusOK,
+		},
+		{
+			name:          "Admin",
+			authenticated: true,
+			role:          models.RoleAdmin,
+			wantCode:      http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.authenticated {
+				ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+				ctx = context.WithValue(ctx, userRoleContextKey, tt.role)
+				r = r.WithContext(ctx)
+			}
+
+			app.requireRole(models.RoleModerator, models.RoleAdmin)(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestAssignRequestID(t *testing.T) {
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A request ID sent by the client should be ignored.
+	r.Header.Set("X-Request-ID", "spoofed")
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	})
+
+	assignRequestID(next).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+
+	assert.Equal(t, len(seen), 32)
+	assert.Equal(t, rs.Header.Get("X-Request-ID"), seen)
+}
+
+func TestRequireScope(t *testing.T) {
+
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantAuth string
+	}{
+		{
+			name:     "Read token",
+			token:    "sbx_read",
+			wantCode: http.StatusForbidden,
+			wantAuth: `Bearer error="insufficient_scope", scope="snippets:write"`,
+		},
+		{
+			name:     "Write token",
+			token:    "sbx_write",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodPost, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+
+			app.authenticateToken(app.requireScope(models.ScopeSnippetsWrite)(next)).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("WWW-Authenticate"), tt.wantAuth)
+		})
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+
+	app := newTestApplication(t)
+	app.replicaStickiness = time.Hour
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, models.UsingPrimary(r.Context()))
+	})
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.readYourWrites(mux)))
+	defer ts.Close()
+
+	// Reads go to the replicas until the session makes a change.
+	_, _, body := ts.get(t, "/")
+	assert.Equal(t, body, "false")
+
+	_, _, body = ts.do(t, http.MethodPost, "/", nil, nil)
+	assert.Equal(t, body, "true")
+
+	// The following reads stay on the primary.
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, body, "true")
+
+	// Without read replicas, the middleware does nothing.
+	app.replicaStickiness = 0
+
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, body, "false")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/High-la/snippetbox/internal/models"
 	"github.com/High-la/snippetbox/ui"
 	"github.com/justinas/alice"
 )
@@ -27,6 +28,20 @@ func (app *application) routes() http.Handler {
 
 	// Add a new GET /ping route.
 	mux.HandleFunc("GET /ping", ping)
+
+	// The Atom feeds don't use sessions, so like /ping they're registered
+	// without the dynamic middleware chain.
+	mux.HandleFunc("GET /feed.atom", app.feedLatest)
+	mux.HandleFunc("GET /user/{ref}/feed.atom", app.feedUser)
+
+	// The embed routes are meant to be shown in iframes on other sites, so
+	// they use a header policy which allows framing. They don't use sessions
+	// either, as the viewer's cookies aren't sent from other sites.
+	embed := alice.New(withHeaderPolicy(embedHeaderPolicy))
+
+	mux.Handle("GET /snippet/embed/{id}", embed.ThenFunc(app.snippetEmbed))
+	mux.HandleFunc("GET /oembed", app.oEmbed)
+
 	// Create a new middleware chain containing the middleware specific to our
 	// dynamic application routes. For now, this chain will only contain the
 	// LoadAndSave session middleware but we'll add more to it later
@@ -35,7 +50,7 @@ func (app *application) routes() http.Handler {
 	// Use the nosurf middleware on all our 'dynamic' routes.
 
 	// Add the authenticate() middleware to the chain.
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate, app.readYourWrites)
 
 	// Swap the route declarations to use the application struct's methods as the
 	// handler function.
@@ -51,6 +66,7 @@ func (app *application) routes() http.Handler {
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
 	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLoginPost))
+	mux.Handle("GET /user/{ref}", dynamic.ThenFunc(app.userProfile))
 
 	// Protected (authenticated-only) application routes, using a new 'protected'
 	// middleware chain which includes the requireAuthentication middleware.
@@ -58,7 +74,64 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
 	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/edit/{id}", protected.ThenFunc(app.snippetEdit))
+	mux.Handle("POST /snippet/edit/{id}", protected.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippet/delete/{id}", protected.ThenFunc(app.snippetDeletePost))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /account", protected.ThenFunc(app.accountView))
+	mux.Handle("POST /account/sessions/revoke", protected.ThenFunc(app.accountSessionRevokePost))
+	mux.Handle("POST /account/sessions/revoke-others", protected.ThenFunc(app.accountSessionRevokeOthersPost))
+	mux.Handle("GET /account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	mux.Handle("POST /account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	mux.Handle("GET /account/delete", protected.ThenFunc(app.accountDelete))
+	mux.Handle("POST /account/delete", protected.ThenFunc(app.accountDeletePost))
+	mux.Handle("GET /account/tokens", protected.ThenFunc(app.accountTokens))
+	mux.Handle("POST /account/tokens/create", protected.ThenFunc(app.accountTokenCreatePost))
+	mux.Handle("POST /account/tokens/revoke", protected.ThenFunc(app.accountTokenRevokePost))
+	mux.Handle("GET /account/webhooks", protected.ThenFunc(app.accountWebhooks))
+	mux.Handle("POST /account/webhooks/create", protected.ThenFunc(app.accountWebhookCreatePost))
+	mux.Handle("POST /account/webhooks/delete", protected.ThenFunc(app.accountWebhookDeletePost))
+	mux.Handle("GET /org/create", protected.ThenFunc(app.orgCreate))
+	mux.Handle("POST /org/create", protected.ThenFunc(app.orgCreatePost))
+	mux.Handle("POST /org/switch", protected.ThenFunc(app.orgSwitchPost))
+	mux.Handle("GET /invite/{token}", protected.ThenFunc(app.orgJoin))
+	mux.Handle("POST /invite/{token}", protected.ThenFunc(app.orgJoinPost))
+	mux.Handle("GET /org/{id}", protected.ThenFunc(app.orgView))
+	mux.Handle("POST /org/{id}/invite", protected.ThenFunc(app.orgInvitePost))
+	mux.Handle("POST /org/{id}/members/remove", protected.ThenFunc(app.orgMemberRemovePost))
+
+	// Admin-only routes, using an 'admin' middleware chain which adds the
+	// requireRole middleware to the protected chain.
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+
+	mux.Handle("GET /admin", admin.ThenFunc(app.adminDashboard))
+	mux.Handle("GET /admin/users", admin.ThenFunc(app.adminUsers))
+	mux.Handle("POST /admin/users/suspend", admin.ThenFunc(app.adminUserSuspendPost))
+	mux.Handle("GET /admin/snippets", admin.ThenFunc(app.adminSnippets))
+	mux.Handle("POST /admin/snippets/delete", admin.ThenFunc(app.adminSnippetDeletePost))
+	mux.Handle("GET /admin/audit", admin.ThenFunc(app.adminAudit))
+
+	// API routes, using an 'api' middleware chain. These authenticate with
+	// personal API tokens instead of the session cookie, so they don't need
+	// the session or CSRF middleware. Every route here must also be described
+	// in ui/api/openapi.json, which TestOpenAPISpec checks.
+	api := alice.New(app.authenticateToken)
+
+	mux.Handle("GET /api/openapi.json", api.ThenFunc(app.apiSpec))
+	mux.Handle("GET /api/v1/me", api.Append(app.requireScope()).ThenFunc(app.apiMe))
+	mux.Handle("GET /api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", api.ThenFunc(app.apiSnippetGet))
+
+	// Routes which change snippets need a token with the snippets:write scope.
+	apiWrite := api.Append(app.requireScope(models.ScopeSnippetsWrite))
+
+	mux.Handle("POST /api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	mux.Handle("PUT /api/v1/snippets/{id}", apiWrite.ThenFunc(app.apiSnippetUpdate))
+	mux.Handle("DELETE /api/v1/snippets/{id}", apiWrite.ThenFunc(app.apiSnippetDelete))
+
+	// Anything else under /api/ gets a JSON 404, rather than the plain text
+	// one sent by the servemux.
+	mux.Handle("/api/", api.ThenFunc(app.apiNotFound))
 
 	// Pass the servemux as the 'next' parameter to the commonHeaders middleware
 	// Because commonHeaders is just a function, and the function returns a
@@ -69,7 +142,7 @@ func (app *application) routes() http.Handler {
 
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used for every request of our app receives.
-	standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
+	standard := alice.New(app.recoverPanic, assignRequestID, app.logRequest, commonHeaders)
 
 	// Retrun the 'standard' middleware chain followed by the servemux.
 	return standard.Then(mux)
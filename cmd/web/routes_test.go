@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+// The apiRoutePatterns helper returns the patterns of the API routes which
+// routes() registers, like "GET /api/v1/snippets". A ServeMux can't list its
+// routes, so they are read from the mux.Handle() and mux.HandleFunc() calls
+// in routes.go instead. Patterns without a method (like the "/api/" catch-all)
+// are left out, because they aren't operations which need documenting.
+func apiRoutePatterns(t *testing.T) []string {
+
+	f, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var patterns []string
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "mux" {
+			return true
+		}
+
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			t.Errorf("route pattern %v is not a string literal", call.Args[0])
+			return true
+		}
+
+		pattern, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		method, path, found := strings.Cut(pattern, " ")
+		if found && strings.HasPrefix(path, "/api/") {
+			patterns = append(patterns, method+" "+path)
+		}
+
+		return true
+	})
+
+	return patterns
+}
+
+func TestOpenAPISpec(t *testing.T) {
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/api/openapi.json")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	err := json.Unmarshal([]byte(body), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.HasPrefix(spec.OpenAPI, "3."), true)
+
+	// Collect the operations described in the spec, in the same form as the
+	// route patterns. Path items can also hold shared fields like
+	// "parameters", which aren't operations.
+	var operations []string
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	routes := apiRoutePatterns(t)
+
+	if len(routes) == 0 {
+		t.Fatal("no API routes found in routes.go")
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(operations, route) {
+			t.Errorf("route %q is registered in routes() but missing from ui/api/openapi.json", route)
+		}
+	}
+
+	for _, operation := range operations {
+		if !slices.Contains(routes, operation) {
+			t.Errorf("operation %q is in ui/api/openapi.json but not registered in routes()", operation)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/High-la/snippetbox/internal/models"
@@ -28,6 +29,14 @@ func humanDate(t time.Time) string {
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"isPast":    isPast,
+	"contains":  slices.Contains[[]string],
+}
+
+// The isPast function reports whether a time is in the past, for example to
+// flag expired snippets.
+func isPast(t time.Time) bool {
+	return t.Before(time.Now())
 }
 
 // Define a templateData type to act as the holding structure for
@@ -41,12 +50,72 @@ var functions = template.FuncMap{
 type templateData struct {
 	CurrentYear int
 	Snippet     models.Snippet
+	IsOwner     bool   // Whether the current user owns the Snippet.
+	CanDelete   bool   // Whether the current user can delete the Snippet.
+	SnippetURL  string // The Snippet's absolute URL, if it can be embedded.
+	EmbedURL    string // The absolute URL of the Snippet's embed page.
 	Snippets    []models.Snippet
 	Form        any
 	Flash       string // Add a Flash field to the templateData struct.
 	// Add an IsAuthenticated field to the templateData struct.
 	IsAuthenticated bool
+	UserRole        string // The current user's role, empty if not authenticated.
 	CSRFToken       string // Add a CSRFToken field.
+	// Fields used by the account page.
+	User             models.User
+	UserSessions     []models.UserSession
+	CurrentSessionID string
+	Pagination       pagination
+	// Fields used by the admin pages.
+	Users       []models.User
+	Query       string
+	AdminCounts adminCounts
+	AuditEvents []models.AuditEvent
+	AuditFilter models.AuditFilter
+	AuditTypes  []string
+	// Fields used by the org switcher and the organisation pages.
+	Orgs            []models.Org
+	CurrentOrg      models.Org
+	Org             models.Org
+	OrgMembers      []models.OrgMember
+	InvitationToken string
+	// Fields used by the API tokens page.
+	APITokens      []models.APIToken
+	APITokenScopes []string
+	NewAPIToken    string
+	// Fields used by the webhooks page.
+	Webhooks          []models.Webhook
+	WebhookDeliveries []models.WebhookDelivery
+}
+
+// The adminCounts type holds the counters shown on the admin dashboard.
+type adminCounts struct {
+	Users        int
+	LiveSnippets int
+	Sessions     int
+	SnippetCache *models.SnippetCacheStats // Nil unless the snippet cache is enabled.
+}
+
+// The pagination type holds the page numbers used to render the previous and
+// next links on paginated pages. A PrevPage or NextPage of zero means there is
+// no such page.
+type pagination struct {
+	Page     int
+	PrevPage int
+	NextPage int
+}
+
+func newPagination(page int, hasNext bool) pagination {
+	p := pagination{Page: page}
+
+	if page > 1 {
+		p.PrevPage = page - 1
+	}
+	if hasNext {
+		p.NextPage = page + 1
+	}
+
+	return p
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -88,6 +157,15 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts
 	}
 
+	// The embed page is a complete document of its own, rather than a page
+	// within base.tmpl.html, because it's shown in iframes on other sites.
+	ts, err := template.New("embed.tmpl.html").Funcs(functions).ParseFS(ui.Files, "html/embed.tmpl.html")
+	if err != nil {
+		return nil, err
+	}
+
+	cache["embed.tmpl.html"] = ts
+
 	// Return the map.
 	return cache, nil
 }
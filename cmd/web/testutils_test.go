@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/High-la/snippetbox/internal/mocks"
+	"github.com/High-la/snippetbox/internal/password"
+	"github.com/High-la/snippetbox/internal/webhooks"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 )
@@ -72,6 +74,11 @@ func extractCSRFToken(t *testing.T, body string) string {
 	return html.UnescapeString(matches[1])
 }
 
+// testHost is the host in the test application's base URL. It's deliberately
+// not the test server's address, so that tests catch absolute URLs built from
+// the request.
+const testHost = "snippetbox.example.com"
+
 // Create a newTestApplication helper whcich returns an instance of our
 // app struct containing mocked dependencies.
 func newTestApplication(t *testing.T) *application {
@@ -93,13 +100,26 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	// The webhook dispatcher isn't started, so queued deliveries stay pending
+	// in the mock model, where tests can check them.
+	webhookModel := &mocks.WebhookModel{}
+	logger := slog.New(slog.DiscardHandler)
+
 	return &application{
-		logger:         slog.New(slog.DiscardHandler),
+		logger:         logger,
 		snippets:       &mocks.SnippetModel{}, // Use the mock.
 		users:          &mocks.UserModel{},    // Use the mock
+		userSessions:   &mocks.UserSessionModel{},
+		orgs:           &mocks.OrgModel{},
+		apiTokens:      &mocks.APITokenModel{},
+		webhooks:       webhookModel,
+		dispatcher:     webhooks.New(webhookModel, logger, webhooks.Config{}),
+		passwordPolicy: &password.Policy{MinLength: 8, MaxLength: 64, Breached: &mocks.BreachedList{}},
+		auditLog:       &mocks.AuditModel{},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		baseURL:        &url.URL{Scheme: "https", Host: testHost},
 	}
 }
 
@@ -164,3 +184,55 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// The do() method sends a request with the given method, headers and body to
+// the test server. It's used for API requests, which need headers like
+// Authorization that get() and postForm() don't set.
+func (ts *testServer) do(t *testing.T, method, urlPath string, header http.Header, body io.Reader) (int, http.Header, string) {
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rs, err := ts.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(b))
+}
+
+// The login() method logs in to the test server as the mock user
+// alice@example.com, so that the client's cookie jar holds an authenticated
+// session for subsequent requests.
+func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "alice@example.com")
+}
+
+// The loginAs() method logs in to the test server as one of the mock users.
+func (ts *testServer) loginAs(t *testing.T, email string) {
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "1234")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The mock tokens belong to alice@example.com (user 1). "sbx_read" can only
+// read snippets, while "sbx_write" can read and write them. A token for user
+// 99 (who doesn't exist) is included to check that it's rejected.
+var mockAPITokens = map[string]models.APIToken{
+	"sbx_read": {
+		ID:      1,
+		UserID:  1,
+		Name:    "Read only",
+		Scopes:  []string{models.ScopeSnippetsRead},
+		Created: time.Now(),
+	},
+	"sbx_write": {
+		ID:      2,
+		UserID:  1,
+		Name:    "CI",
+		Scopes:  []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+		Created: time.Now(),
+	},
+	"sbx_deleted_user": {
+		ID:      3,
+		UserID:  99,
+		Name:    "Orphan",
+		Scopes:  []string{models.ScopeSnippetsRead},
+		Created: time.Now(),
+	},
+}
+
+// The APITokenModel mock starts with the tokens above, and keeps any tokens
+// created or revoked by a test in memory.
+type APITokenModel struct {
+	mu     sync.Mutex
+	tokens map[string]models.APIToken
+}
+
+func (m *APITokenModel) init() {
+	if m.tokens == nil {
+		m.tokens = make(map[string]models.APIToken)
+		for k, v := range mockAPITokens {
+			m.tokens[k] = v
+		}
+	}
+}
+
+func (m *APITokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	id := len(m.tokens) + 1
+	token := fmt.Sprintf("sbx_test_%d", id)
+
+	m.tokens[token] = models.APIToken{
+		ID:      id,
+		UserID:  userID,
+		Name:    name,
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+
+	return token, nil
+}
+
+func (m *APITokenModel) ForUser(ctx context.Context, userID int) ([]models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	var tokens []models.APIToken
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (m *APITokenModel) Revoke(ctx context.Context, userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for k, t := range m.tokens {
+		if t.UserID == userID && t.ID == id {
+			delete(m.tokens, k)
+		}
+	}
+
+	return nil
+}
+
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	t, ok := m.tokens[token]
+	if !ok {
+		return models.APIToken{}, models.ErrNoRecord
+	}
+
+	t.LastUsed = time.Now()
+	m.tokens[token] = t
+
+	return t, nil
+}
@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The AuditModel mock is an in-memory sink for audit events, so that tests can
+// check what was recorded.
+type AuditModel struct {
+	mu     sync.Mutex
+	Events []models.AuditEvent
+}
+
+func (m *AuditModel) Insert(ctx context.Context, event models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = len(m.Events) + 1
+	m.Events = append(m.Events, event)
+	return nil
+}
+
+func (m *AuditModel) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, e := range slices.Backward(m.Events) {
+		if filter.ActorID != 0 && e.ActorID != filter.ActorID {
+			continue
+		}
+		if filter.Type != "" && e.Type != filter.Type {
+			continue
+		}
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// The Types method returns the types of the recorded events, in order.
+func (m *AuditModel) Types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var types []string
+	for _, e := range m.Events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The Migrator mock pretends to have two migrations, and starts out with
+// neither of them applied.
+type Migrator struct {
+	version int
+}
+
+const mockLatestMigration = 2
+
+func (m *Migrator) migration(version int) models.Migration {
+	return models.Migration{Version: version, Name: fmt.Sprintf("%04d_mock", version)}
+}
+
+func (m *Migrator) Version() (int, error) {
+	return m.version, nil
+}
+
+func (m *Migrator) Latest() (int, error) {
+	return mockLatestMigration, nil
+}
+
+func (m *Migrator) Up() ([]models.Migration, error) {
+	var applied []models.Migration
+	for v := m.version + 1; v <= mockLatestMigration; v++ {
+		applied = append(applied, m.migration(v))
+	}
+	m.version = mockLatestMigration
+
+	return applied, nil
+}
+
+func (m *Migrator) Down(steps int) ([]models.Migration, error) {
+	version := m.version
+
+	var undone []models.Migration
+	for ; version > 0 && len(undone) < steps; version-- {
+		undone = append(undone, m.migration(version))
+	}
+	m.version = version
+
+	return undone, nil
+}
+
+func (m *Migrator) Force(version int) error {
+	if version < 0 || version > mockLatestMigration {
+		return fmt.Errorf("migrations: version must be between 0 and %d", mockLatestMigration)
+	}
+	m.version = version
+	return nil
+}
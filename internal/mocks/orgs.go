@@ -0,0 +1,110 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The mock organisation has ID 1, with alice@example.com (user 1) as its only
+// member and owner.
+var mockOrg = models.Org{
+	ID:      1,
+	Name:    "Acme",
+	Created: time.Now(),
+	Role:    models.OrgRoleOwner,
+}
+
+var mockOrgSnippet = models.Snippet{
+	ID:         4,
+	UserID:     1,
+	OrgID:      1,
+	Title:      "Team notes",
+	Content:    "Only Acme members can read this.",
+	Created:    time.Now(),
+	Expires:    time.Now().AddDate(0, 0, 7),
+	Visibility: models.SnippetPrivate,
+}
+
+type OrgModel struct{}
+
+func (m *OrgModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+	return 2, nil
+}
+
+func (m *OrgModel) Get(ctx context.Context, orgID, userID int) (models.Org, error) {
+	if orgID == 1 && userID == 1 {
+		return mockOrg, nil
+	}
+	return models.Org{}, models.ErrNoRecord
+}
+
+func (m *OrgModel) ForUser(ctx context.Context, userID int) ([]models.Org, error) {
+	if userID == 1 {
+		return []models.Org{mockOrg}, nil
+	}
+	return nil, nil
+}
+
+func (m *OrgModel) Members(ctx context.Context, orgID, userID int) ([]models.OrgMember, error) {
+	if orgID == 1 && userID == 1 {
+		return []models.OrgMember{
+			{UserID: 1, Name: mockUser.Name, Email: mockUser.Email, Role: models.OrgRoleOwner, Joined: time.Now()},
+		}, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *OrgModel) RemoveMember(ctx context.Context, orgID, actorID, userID int) error {
+	if orgID != 1 || actorID != 1 {
+		return models.ErrNoRecord
+	}
+	if userID == actorID {
+		return models.ErrPermissionDenied
+	}
+	return models.ErrNoRecord
+}
+
+// The token "valid-token" is an invitation to the mock organisation.
+func (m *OrgModel) CreateInvitation(ctx context.Context, orgID, actorID int, role string) (string, error) {
+	if orgID == 1 && actorID == 1 {
+		return "valid-token", nil
+	}
+	return "", models.ErrNoRecord
+}
+
+func (m *OrgModel) AcceptInvitation(ctx context.Context, token string, userID int) (int, error) {
+	if token == "valid-token" {
+		return 1, nil
+	}
+	return 0, models.ErrNoRecord
+}
+
+func (m *OrgModel) InsertSnippet(ctx context.Context, orgID, userID int, title, content string, expires int) (int, error) {
+	if orgID == 1 && userID == 1 {
+		return 5, nil
+	}
+	return 0, models.ErrNoRecord
+}
+
+func (m *OrgModel) Snippet(ctx context.Context, id, userID int) (models.Snippet, error) {
+	if id == 4 && userID == 1 {
+		return mockOrgSnippet, nil
+	}
+	return models.Snippet{}, models.ErrNoRecord
+}
+
+func (m *OrgModel) DeleteSnippet(ctx context.Context, id, userID int) error {
+	if id == 4 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *OrgModel) Snippets(ctx context.Context, orgID, userID int) ([]models.Snippet, error) {
+	if orgID == 1 && userID == 1 {
+		return []models.Snippet{mockOrgSnippet}, nil
+	}
+	return nil, models.ErrNoRecord
+}
@@ -0,0 +1,8 @@
+package mocks
+
+// The BreachedList mock treats a single well-known password as breached.
+type BreachedList struct{}
+
+func (b *BreachedList) Contains(plaintext string) (bool, error) {
+	return plaintext == "password123", nil
+}
@@ -1,36 +1,90 @@
 package mocks
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/High-la/snippetbox/internal/models"
 )
 
 var mockSnippet = models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Created:    time.Now(),
+	Expires:    time.Now().AddDate(0, 0, 7),
+	Visibility: models.SnippetPublic,
+}
+
+var mockPrivateSnippet = models.Snippet{
+	ID:         3,
+	UserID:     1,
+	Title:      "A private note",
+	Content:    "Only Alice can read this.",
+	Created:    time.Now(),
+	Expires:    time.Now().AddDate(0, 0, 7),
+	Visibility: models.SnippetPrivate,
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int, visibility string) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (models.Snippet, error) {
 
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockPrivateSnippet, nil
+	case 98:
+		// Snippet 98 stands in for a query which ran past the query timeout.
+		return models.Snippet{}, fmt.Errorf("%w: %w", models.ErrTimeout, context.DeadlineExceeded)
 	default:
 		return models.Snippet{}, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]models.Snippet, error) {
+func (m *SnippetModel) Update(ctx context.Context, id int, title, content string, expires int, visibility string) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]models.Snippet, error) {
 
 	return []models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ForUser(ctx context.Context, userID, page, pageSize int) ([]models.Snippet, bool, error) {
+
+	if userID == 1 && page == 1 {
+		return []models.Snippet{mockSnippet}, false, nil
+	}
+
+	return nil, false, nil
+}
+
+func (m *SnippetModel) Recent(ctx context.Context, limit int) ([]models.Snippet, error) {
+	return []models.Snippet{mockPrivateSnippet, mockSnippet}, nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) CountLive(ctx context.Context) (int, error) {
+	return 2, nil
+}
@@ -1,35 +1,128 @@
 package mocks
 
-import "github.com/High-la/snippetbox/internal/models"
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+var mockUser = models.User{
+	ID:      1,
+	Name:    "Alice",
+	Email:   "alice@example.com",
+	Handle:  "alice",
+	Role:    models.RoleUser,
+	Created: time.Now(),
+}
 
-	switch email {
-	case "test@example.com":
+var mockAdmin = models.User{
+	ID:      2,
+	Name:    "Admin",
+	Email:   "admin@example.com",
+	Role:    models.RoleAdmin,
+	Created: time.Now(),
+}
+
+func (m *UserModel) Insert(ctx context.Context, name, email, handle, password string) error {
+
+	switch {
+	case email == "test@example.com":
 		return models.ErrDuplicateEmail
+	case handle == "alice":
+		return models.ErrDuplicateHandle
 	default:
 		return nil
 	}
 
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 
 	if email == "alice@example.com" && password == "1234" {
 		return 1, nil
 	}
 
+	if email == "admin@example.com" && password == "1234" {
+		return 2, nil
+	}
+
+	if email == "suspended@example.com" && password == "1234" {
+		return 0, models.ErrUserSuspended
+	}
+
 	return 0, models.ErrInvalidCredentials
 
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
+
+func (m *UserModel) Get(ctx context.Context, id int) (models.User, error) {
+	switch id {
+	case 1:
+		return mockUser, nil
+	case 2:
+		return mockAdmin, nil
+	default:
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByHandle(ctx context.Context, handle string) (models.User, error) {
+	switch handle {
+	case "alice":
+		return mockUser, nil
+	default:
+		return models.User{}, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) Delete(ctx context.Context, id int, anonymiseSnippets bool) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, plaintext string) error {
+	return nil
+}
+
+func (m *UserModel) SetRole(ctx context.Context, email, role string) error {
+	switch email {
+	case "alice@example.com", "admin@example.com":
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) Search(ctx context.Context, query string, limit int) ([]models.User, error) {
+	var users []models.User
+	for _, u := range []models.User{mockAdmin, mockUser} {
+		if strings.Contains(u.Name, query) || strings.Contains(u.Email, query) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+	return nil
+}
+
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	return 2, nil
+}
@@ -0,0 +1,113 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The UserSessionModel mock keeps its sessions in memory, so that tests can
+// log in, list the sessions and then check that revoked sessions are rejected.
+type UserSessionModel struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[string]models.UserSession
+}
+
+func (m *UserSessionModel) Insert(ctx context.Context, userID int, token, userAgent, ip string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions == nil {
+		m.sessions = make(map[string]models.UserSession)
+	}
+
+	m.nextID++
+	id := fmt.Sprintf("session-%d", m.nextID)
+
+	m.sessions[id] = models.UserSession{
+		ID:        id,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		Created:   time.Now(),
+		LastSeen:  time.Now(),
+	}
+
+	return id, nil
+}
+
+func (m *UserSessionModel) Get(ctx context.Context, id string) (models.UserSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return models.UserSession{}, models.ErrNoRecord
+	}
+
+	return s, nil
+}
+
+func (m *UserSessionModel) ForUser(ctx context.Context, userID int) ([]models.UserSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []models.UserSession
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+
+	return sessions, nil
+}
+
+func (m *UserSessionModel) Touch(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[id]; ok {
+		s.LastSeen = time.Now()
+		m.sessions[id] = s
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) Revoke(ctx context.Context, userID int, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[id]; ok && s.UserID == userID {
+		delete(m.sessions, id)
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) RevokeAllExcept(ctx context.Context, userID int, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for sid, s := range m.sessions {
+		if s.UserID == userID && sid != id {
+			delete(m.sessions, sid)
+		}
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) Count(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.sessions), nil
+}
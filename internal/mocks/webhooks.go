@@ -0,0 +1,171 @@
+package mocks
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The mock webhook belongs to alice@example.com (user 1).
+var mockWebhook = models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://example.com/hooks/snippets",
+	Secret:  "mock-secret",
+	Created: time.Now(),
+}
+
+// The WebhookModel mock keeps webhooks and deliveries in memory. Webhooks
+// starts with the mock webhook above, unless a test sets it first. Deliveries
+// are kept in Log, so that tests can check what was recorded, and Expired
+// holds the snippets which the next ClaimExpiredSnippets call returns.
+type WebhookModel struct {
+	mu       sync.Mutex
+	Webhooks []models.Webhook
+	Log      []models.WebhookDelivery
+	Expired  []models.Snippet
+}
+
+func (m *WebhookModel) init() {
+	if m.Webhooks == nil {
+		m.Webhooks = []models.Webhook{mockWebhook}
+	}
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, userID int, url string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	id := len(m.Webhooks) + 1
+	m.Webhooks = append(m.Webhooks, models.Webhook{
+		ID:      id,
+		UserID:  userID,
+		URL:     url,
+		Secret:  "mock-secret",
+		Created: time.Now(),
+	})
+
+	return id, nil
+}
+
+func (m *WebhookModel) ForUser(ctx context.Context, userID int) ([]models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	var webhooks []models.Webhook
+	for _, w := range m.Webhooks {
+		if w.UserID == userID {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (m *WebhookModel) Delete(ctx context.Context, userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	i := slices.IndexFunc(m.Webhooks, func(w models.Webhook) bool {
+		return w.UserID == userID && w.ID == id
+	})
+	if i == -1 {
+		return models.ErrNoRecord
+	}
+
+	m.Webhooks = slices.Delete(m.Webhooks, i, i+1)
+
+	return nil
+}
+
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	d := models.WebhookDelivery{
+		ID:        len(m.Log) + 1,
+		WebhookID: webhookID,
+		Event:     event,
+		Status:    models.DeliveryPending,
+		Created:   time.Now(),
+		Updated:   time.Now(),
+	}
+
+	for _, w := range m.Webhooks {
+		if w.ID == webhookID {
+			d.URL = w.URL
+		}
+	}
+
+	m.Log = append(m.Log, d)
+
+	return d.ID, nil
+}
+
+func (m *WebhookModel) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.Log {
+		if m.Log[i].ID == d.ID {
+			m.Log[i].Status = d.Status
+			m.Log[i].Attempts = d.Attempts
+			m.Log[i].StatusCode = d.StatusCode
+			m.Log[i].Error = d.Error
+			m.Log[i].Updated = time.Now()
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, userID, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	var deliveries []models.WebhookDelivery
+	for _, d := range slices.Backward(m.Log) {
+		for _, w := range m.Webhooks {
+			if w.ID == d.WebhookID && w.UserID == userID && len(deliveries) < limit {
+				deliveries = append(deliveries, d)
+			}
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (m *WebhookModel) ClaimExpiredSnippets(ctx context.Context, limit int) ([]models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := min(limit, len(m.Expired))
+	snippets := m.Expired[:n]
+	m.Expired = m.Expired[n:]
+
+	return snippets, nil
+}
+
+// The Delivery method returns a copy of the delivery with the given ID, so
+// that tests can check its state while deliveries are still being made.
+func (m *WebhookModel) Delivery(id int) models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.Log {
+		if d.ID == id {
+			return d
+		}
+	}
+
+	return models.WebhookDelivery{}
+}
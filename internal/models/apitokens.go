@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+type APITokenModelInterface interface {
+	Insert(ctx context.Context, userID int, name string, scopes []string) (string, error)
+	ForUser(ctx context.Context, userID int) ([]APIToken, error)
+	Revoke(ctx context.Context, userID, id int) error
+	Authenticate(ctx context.Context, token string) (APIToken, error)
+}
+
+// The scopes which can be granted to an API token.
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+)
+
+// APITokenScopes lists every scope, in the order they should be offered.
+var APITokenScopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+// apiTokenPrefix is added to the front of every token, so that they are easy
+// to recognise (for example by secret scanners) if they are leaked.
+const apiTokenPrefix = "sbx_"
+
+// Define an APIToken type to hold a personal API token. The token itself is
+// only shown to the user once, when it is created; after that only its hash
+// is kept. LastUsed is the zero time if the token has never been used.
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+// HasScope reports whether the token has been granted the given scope.
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Define an APITokenModel type which wraps a sql.DB connection pool, and the
+// dialect of the database it's connected to.
+type APITokenModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+}
+
+// hashAPIToken returns the value stored in the database for a token. The
+// tokens are long and random, so a fast unsalted hash is enough here (unlike
+// for passwords), and it lets us look tokens up directly.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Insert creates a new token for the user and returns it.
+func (m *APITokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (string, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created)
+			 VALUES(?, ?, ?, ?, ?)`
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), userID, name, hashAPIToken(token), strings.Join(scopes, ","), utcNow())
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	return token, nil
+}
+
+// The apiTokenColumns constant and scanAPIToken function keep the list of
+// selected columns and the fields they are scanned into in one place.
+const apiTokenColumns = `id, user_id, name, scopes, created, last_used`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (APIToken, error) {
+	var t APIToken
+	var scopes string
+	var lastUsed sql.NullTime
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &lastUsed)
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
+
+// ForUser returns all of a user's tokens, newest first.
+func (m *APITokenModel) ForUser(ctx context.Context, userID int) ([]APIToken, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return tokens, nil
+}
+
+// Revoke deletes one of the user's tokens. The user ID is part of the WHERE
+// clause so that one user can never revoke another user's token.
+func (m *APITokenModel) Revoke(ctx context.Context, userID, id int) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM api_tokens WHERE user_id = ? AND id = ?`), userID, id)
+	return queryError(ctx, err)
+}
+
+// Authenticate returns the token matching the given plain-text token, and
+// records that it has been used (to the nearest minute). It returns ErrNoRecord if there is no such
+// token (for example because it has been revoked).
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (APIToken, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return APIToken{}, ErrNoRecord
+	}
+
+	hash := hashAPIToken(token)
+
+	stmt := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = ?`
+
+	t, err := scanAPIToken(m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, ErrNoRecord
+		} else {
+			return APIToken{}, queryError(ctx, err)
+		}
+	}
+
+	// Record when the token was used, but no more than once a minute, so that
+	// a busy API client doesn't write to the database on every request.
+	if time.Since(t.LastUsed) > time.Minute {
+		_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(`UPDATE api_tokens SET last_used = ? WHERE id = ?`), utcNow(), t.ID)
+		if err != nil {
+			return APIToken{}, queryError(ctx, err)
+		}
+	}
+
+	return t, nil
+}
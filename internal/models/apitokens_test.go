@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestAPITokenModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := APITokenModel{DB: db, Dialect: testDialect}
+
+	token, err := m.Insert(t.Context(), 1, "CI", []string{ScopeSnippetsRead, ScopeSnippetsWrite})
+	assert.NilError(t, err)
+
+	// Only the hash of the token is stored.
+	var stored string
+	err = db.QueryRow(`SELECT token_hash FROM api_tokens`).Scan(&stored)
+	assert.NilError(t, err)
+	assert.Equal(t, stored, hashAPIToken(token))
+
+	tokens, err := m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), true)
+
+	got, err := m.Authenticate(t.Context(), token)
+	assert.NilError(t, err)
+	assert.Equal(t, got.Name, "CI")
+	assert.Equal(t, got.HasScope(ScopeSnippetsWrite), true)
+
+	tokens, err = m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), false)
+
+	// The last used time is only written once a minute. Move it back so that
+	// it would change if it were written again.
+	earlier := time.Now().UTC().Add(-30 * time.Second).Truncate(time.Second)
+	_, err = db.Exec(testDialect.rebind(`UPDATE api_tokens SET last_used = ? WHERE id = ?`), earlier, got.ID)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(t.Context(), token)
+	assert.NilError(t, err)
+
+	tokens, err = m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens[0].LastUsed.Equal(earlier), true)
+
+	earlier = earlier.Add(-time.Minute)
+	_, err = db.Exec(testDialect.rebind(`UPDATE api_tokens SET last_used = ? WHERE id = ?`), earlier, got.ID)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(t.Context(), token)
+	assert.NilError(t, err)
+
+	tokens, err = m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens[0].LastUsed.After(earlier), true)
+
+	// Another user must not be able to revoke the token.
+	err = m.Revoke(t.Context(), 2, got.ID)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(t.Context(), token)
+	assert.NilError(t, err)
+
+	err = m.Revoke(t.Context(), 1, got.ID)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(t.Context(), token)
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type AuditModelInterface interface {
+	Insert(ctx context.Context, event AuditEvent) error
+	List(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// The event types recorded in the audit log.
+const (
+	AuditUserSignup           = "user.signup"
+	AuditUserLogin            = "user.login"
+	AuditUserLoginFailed      = "user.login_failed"
+	AuditUserLogout           = "user.logout"
+	AuditUserPasswordChanged  = "user.password_changed"
+	AuditUserDeleted          = "user.deleted"
+	AuditAPITokenCreated      = "api_token.created"
+	AuditAPITokenRevoked      = "api_token.revoked"
+	AuditWebhookCreated       = "webhook.created"
+	AuditWebhookDeleted       = "webhook.deleted"
+	AuditSnippetCreated       = "snippet.created"
+	AuditSnippetUpdated       = "snippet.updated"
+	AuditSnippetDeleted       = "snippet.deleted"
+	AuditOrgCreated           = "org.created"
+	AuditOrgMemberInvited     = "org.member_invited"
+	AuditOrgMemberJoined      = "org.member_joined"
+	AuditOrgMemberRemoved     = "org.member_removed"
+	AuditAdminUserSuspended   = "admin.user_suspended"
+	AuditAdminUserUnsuspended = "admin.user_unsuspended"
+	AuditAdminSnippetDeleted  = "admin.snippet_deleted"
+)
+
+// AuditEventTypes lists every event type, in the order they should be offered
+// when filtering the log.
+var AuditEventTypes = []string{
+	AuditUserSignup,
+	AuditUserLogin,
+	AuditUserLoginFailed,
+	AuditUserLogout,
+	AuditUserPasswordChanged,
+	AuditUserDeleted,
+	AuditAPITokenCreated,
+	AuditAPITokenRevoked,
+	AuditWebhookCreated,
+	AuditWebhookDeleted,
+	AuditSnippetCreated,
+	AuditSnippetUpdated,
+	AuditSnippetDeleted,
+	AuditOrgCreated,
+	AuditOrgMemberInvited,
+	AuditOrgMemberJoined,
+	AuditOrgMemberRemoved,
+	AuditAdminUserSuspended,
+	AuditAdminUserUnsuspended,
+	AuditAdminSnippetDeleted,
+}
+
+// Define an AuditEvent type to hold a single entry in the audit log. ActorID
+// is the user who performed the action (zero if nobody was logged in, like
+// for a failed login), IP, UserAgent and RequestID describe the request it
+// was performed in, and Details holds any structured data about it (like the
+// ID of the user or snippet affected), stored as JSON.
+type AuditEvent struct {
+	ID        int
+	ActorID   int
+	Type      string
+	IP        string
+	UserAgent string
+	RequestID string
+	Details   map[string]any
+	Created   time.Time
+}
+
+// The AuditFilter type holds the criteria for listing audit events. Zero
+// values mean "don't filter on this".
+type AuditFilter struct {
+	ActorID int
+	Type    string
+	Limit   int
+}
+
+// Define an AuditModel type which wraps a sql.DB connection pool. The audit
+// log is append-only, so there are no methods to update or delete entries.
+type AuditModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+}
+
+// Insert appends an event to the audit log.
+func (m *AuditModel) Insert(ctx context.Context, event AuditEvent) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	// The user_agent column is limited to 255 characters, in the same way as
+	// it is for user_sessions.
+	if runes := []rune(event.UserAgent); len(runes) > 255 {
+		event.UserAgent = string(runes[:255])
+	}
+
+	stmt := `INSERT INTO audit_events (actor_id, type, ip, user_agent, request_id, details, created)
+			 VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	actorID := sql.NullInt64{Int64: int64(event.ActorID), Valid: event.ActorID != 0}
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), actorID, event.Type, event.IP, event.UserAgent, event.RequestID, details, utcNow())
+	return queryError(ctx, err)
+}
+
+// List returns the audit events matching the filter, newest first.
+func (m *AuditModel) List(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT id, actor_id, type, ip, user_agent, request_id, details, created
+			 FROM audit_events WHERE 1 = 1`
+	var args []any
+
+	if filter.ActorID != 0 {
+		stmt += ` AND actor_id = ?`
+		args = append(args, filter.ActorID)
+	}
+	if filter.Type != "" {
+		stmt += ` AND type = ?`
+		args = append(args, filter.Type)
+	}
+
+	stmt += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), args...)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+		var actorID sql.NullInt64
+		var details []byte
+
+		err = rows.Scan(&e.ID, &actorID, &e.Type, &e.IP, &e.UserAgent, &e.RequestID, &details, &e.Created)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		err = json.Unmarshal(details, &e.Details)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		e.ActorID = int(actorID.Int64)
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,43 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestAuditModelList(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := AuditModel{DB: db, Dialect: testDialect}
+
+	events := []AuditEvent{
+		{Type: AuditUserLoginFailed, IP: "192.0.2.1", Details: map[string]any{"email": "alice@example.com"}},
+		{ActorID: 1, Type: AuditUserLogin, IP: "192.0.2.1", RequestID: "abc"},
+		{ActorID: 1, Type: AuditSnippetCreated, Details: map[string]any{"snippetID": 1}},
+	}
+
+	for _, e := range events {
+		err := m.Insert(t.Context(), e)
+		assert.NilError(t, err)
+	}
+
+	all, err := m.List(t.Context(), AuditFilter{Limit: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 3)
+	assert.Equal(t, all[0].Type, AuditSnippetCreated)
+	assert.Equal(t, all[2].ActorID, 0)
+	assert.Equal(t, all[2].Details["email"], any("alice@example.com"))
+
+	byUser, err := m.List(t.Context(), AuditFilter{ActorID: 1, Type: AuditUserLogin, Limit: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, len(byUser), 1)
+	assert.Equal(t, byUser[0].RequestID, "abc")
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The queryContext() function returns the context which a model method runs
+// its queries with. It's the caller's context (usually the HTTP request's, so
+// the queries stop if the client goes away), with the model's QueryTimeout on
+// top. A timeout of zero leaves just the caller's context.
+func queryContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// The queryError() function turns an error from a query into ErrCanceled or
+// ErrTimeout if the query's context was done, wrapping the original error.
+// The drivers don't agree on what they return when a query is stopped part
+// way through (SQLite reports it as "interrupted", for example), so it's the
+// context which is checked, rather than the error. Any other error is
+// returned unchanged.
+func queryError(ctx context.Context, err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	switch ctx.Err() {
+	case context.Canceled:
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	default:
+		return err
+	}
+}
+
+type contextKey string
+
+const primaryContextKey = contextKey("primary")
+
+// WithPrimary returns a copy of the context which sends every query to the
+// primary database, even in methods which would normally read from a
+// replica. It's for reads which must see the caller's own recent writes,
+// which may not have reached the replicas yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey, true)
+}
+
+// UsingPrimary reports whether the context was made by WithPrimary().
+func UsingPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey).(bool)
+	return primary
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestQueryError(t *testing.T) {
+
+	driverErr := errors.New("interrupted")
+
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	timedOut, cancel := context.WithTimeout(t.Context(), -time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		wantErr error
+	}{
+		{name: "No error", ctx: canceled, err: nil, wantErr: nil},
+		{name: "Context still running", ctx: t.Context(), err: driverErr, wantErr: driverErr},
+		{name: "Canceled", ctx: canceled, err: driverErr, wantErr: ErrCanceled},
+		{name: "Timed out", ctx: timedOut, err: driverErr, wantErr: ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryError(tt.ctx, tt.err)
+
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			if tt.err != nil {
+				// The driver's error is still available.
+				assert.Equal(t, errors.Is(err, tt.err), true)
+			}
+		})
+	}
+}
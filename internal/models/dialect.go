@@ -0,0 +1,256 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The Dialect type identifies the SQL database which the models are talking
+// to, and smooths over the differences between them. The SQL in the models is
+// written with "?" placeholders, and anything which can't be written the same
+// way for every database goes through a Dialect method.
+//
+// The zero value is MySQL, so a model created with just a DB field works the
+// same way as it always has.
+type Dialect string
+
+const (
+	MySQL      Dialect = "mysql"
+	PostgreSQL Dialect = "postgres"
+	SQLite     Dialect = "sqlite"
+)
+
+// sqliteScheme is the prefix of a SQLite DSN. The rest of the DSN is the path
+// of the database file, such as "sqlite://snippetbox.db" for a file in the
+// working directory or "sqlite:///var/lib/snippetbox/snippetbox.db".
+const sqliteScheme = "sqlite://"
+
+// DialectFromDSN picks the dialect for a data source name. PostgreSQL DSNs
+// are URLs with a postgres:// or postgresql:// scheme, SQLite DSNs start with
+// sqlite://, and anything else is treated as a MySQL DSN, such as
+// "web:pass@tcp(localhost:3306)/snippetbox".
+func DialectFromDSN(dsn string) Dialect {
+
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return PostgreSQL
+	case strings.HasPrefix(dsn, sqliteScheme):
+		return SQLite
+	default:
+		return MySQL
+	}
+}
+
+// DriverName returns the name of the database/sql driver for the dialect.
+// The driver itself still needs to be imported by the main package.
+func (d Dialect) DriverName() string {
+
+	switch d {
+	case PostgreSQL:
+		return "pgx"
+	case SQLite:
+		return "sqlite"
+	default:
+		return "mysql"
+	}
+}
+
+// DriverDSN turns a DSN into the form the dialect's driver expects. MySQL and
+// PostgreSQL DSNs are passed through unchanged. For SQLite the sqlite://
+// scheme is removed, and some settings are added unless the DSN already has
+// a query string:
+//
+//   - busy_timeout makes a connection wait for another one's write to finish,
+//     rather than failing straight away, as SQLite only allows one writer.
+//   - journal_mode(WAL) lets reads carry on while a write is in progress.
+//   - _txlock=immediate takes the write lock when a transaction begins, so
+//     that a transaction which reads and then writes can't be caught out by
+//     a write made in between. This does the job of SELECT ... FOR UPDATE.
+//   - _time_format=datetime stores times as "2006-01-02 15:04:05" text, the
+//     same as a MySQL DATETIME. Times are always UTC (see utcNow), so this
+//     sorts and compares in time order.
+func (d Dialect) DriverDSN(dsn string) string {
+
+	if d != SQLite {
+		return dsn
+	}
+
+	path := strings.TrimPrefix(dsn, sqliteScheme)
+	if strings.Contains(path, "?") {
+		return path
+	}
+
+	return path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=datetime"
+}
+
+// The rebind() method rewrites the "?" placeholders in a statement into the
+// form the database expects. PostgreSQL numbers its placeholders ($1, $2 and
+// so on). Question marks inside quoted strings are left alone.
+func (d Dialect) rebind(stmt string) string {
+
+	if d != PostgreSQL {
+		return stmt
+	}
+
+	var b strings.Builder
+	n := 0
+	quoted := false
+
+	for _, r := range stmt {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// The execQuerier interface is satisfied by both *sql.DB and *sql.Tx, so that
+// the Dialect helpers can be used inside transactions.
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// The insert() method runs an INSERT statement and returns the ID of the new
+// row. MySQL reports the ID through LastInsertId(), but PostgreSQL doesn't
+// support it, so a RETURNING clause is added instead. If the statement didn't
+// insert anything (which can happen with INSERT ... SELECT), ErrNoRecord is
+// returned.
+func (d Dialect) insert(ctx context.Context, db execQuerier, stmt string, args ...any) (int, error) {
+
+	if d == PostgreSQL {
+		var id int
+
+		err := db.QueryRowContext(ctx, d.rebind(stmt)+" RETURNING id", args...).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, ErrNoRecord
+			} else {
+				return 0, queryError(ctx, err)
+			}
+		}
+
+		return id, nil
+	}
+
+	result, err := db.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+	if rows == 0 {
+		return 0, ErrNoRecord
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return int(id), nil
+}
+
+// The insertIgnore() method turns the rest of an INSERT statement (starting
+// with "INTO") into one which silently skips rows that would break a unique
+// or primary key constraint.
+func (d Dialect) insertIgnore(into string) string {
+
+	switch d {
+	case PostgreSQL:
+		return d.rebind("INSERT " + into + " ON CONFLICT DO NOTHING")
+	case SQLite:
+		return "INSERT OR IGNORE " + into
+	default:
+		return "INSERT IGNORE " + into
+	}
+}
+
+// The forUpdate() method returns the clause which locks the rows read by a
+// SELECT statement until the end of the transaction. SQLite doesn't have
+// row locks, but it doesn't need them either, because its transactions take
+// the write lock for the whole database when they begin (see DriverDSN).
+func (d Dialect) forUpdate() string {
+
+	if d == SQLite {
+		return ""
+	}
+
+	return " FOR UPDATE"
+}
+
+// The likeEscape() method returns the ESCAPE clause which makes a backslash
+// the escape character in a LIKE pattern. MySQL and PostgreSQL use a
+// backslash by default, but SQLite has no escape character unless one is
+// given.
+func (d Dialect) likeEscape() string {
+
+	if d == SQLite {
+		return ` ESCAPE '\'`
+	}
+
+	return ""
+}
+
+// SQLite error messages name the columns of a unique constraint rather than
+// the constraint itself, for example "UNIQUE constraint failed: users.email".
+// uniqueConstraintColumns maps the names of the constraints which the models
+// check for to the columns SQLite reports.
+var uniqueConstraintColumns = map[string]string{
+	"users_uc_email":           "users.email",
+	"users_uc_handle":          "users.handle",
+	"api_tokens_uc_token_hash": "api_tokens.token_hash",
+}
+
+// The isUniqueViolation() method reports whether an error was caused by a
+// row breaking the named unique constraint. MySQL only gives the name of the
+// constraint in the error message, while PostgreSQL has a field for it.
+func (d Dialect) isUniqueViolation(err error, constraint string) bool {
+
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		return pgError.Code == "23505" && pgError.ConstraintName == constraint
+	}
+
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		columns, ok := uniqueConstraintColumns[constraint]
+		return ok && sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+			strings.Contains(sqliteError.Error(), "UNIQUE constraint failed: "+columns+" ")
+	}
+
+	return false
+}
+
+// The utcNow() function returns the current time, to be stored in the
+// database. Times are passed in as parameters, rather than using a SQL
+// function like UTC_TIMESTAMP(), because every database spells those
+// differently. They're truncated to whole seconds to match the precision of
+// the DATETIME columns.
+func utcNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
@@ -12,4 +12,28 @@ var (
 	// Add a new ErrDuplicateEmail Error. We'll use this later if a user
 	// tries to signup with and email address that's already in use.
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrDuplicateHandle is returned if a user tries to signup with a profile
+	// handle that somebody else has already chosen.
+	ErrDuplicateHandle = errors.New("models: duplicate handle")
+
+	// ErrUserSuspended is returned if a suspended user tries to login with
+	// the correct password.
+	ErrUserSuspended = errors.New("models: user suspended")
+
+	// ErrPermissionDenied is returned if a user tries to do something which
+	// their role doesn't allow, like a member of an organisation trying to
+	// invite somebody else.
+	ErrPermissionDenied = errors.New("models: permission denied")
+
+	// ErrSchemaVersion is returned if the database's schema isn't at the
+	// version of the newest migration.
+	ErrSchemaVersion = errors.New("models: schema version mismatch")
+
+	// ErrCanceled is returned if a query was stopped because its context was
+	// canceled, which usually means the client went away before the response
+	// was ready. ErrTimeout is returned if a query took longer than the
+	// model's QueryTimeout, or ran past the caller's deadline.
+	ErrCanceled = errors.New("models: query canceled")
+	ErrTimeout  = errors.New("models: query timed out")
 )
@@ -0,0 +1,348 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The SQL for each migration lives in the migrations directory, with a
+// sub-directory for each dialect. Every migration has an up file, which
+// changes the schema, and a down file, which undoes the change. The file
+// names start with the version number, such as "0002_add_tags.up.sql" and
+// "0002_add_tags.down.sql", and the versions must count up from 1 without
+// any gaps. The files are embedded in the binary, so a deployment only needs
+// the binary to set up its database.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// MigratorInterface is implemented by Migrator, and by a mock in the tests
+// for the command-line commands.
+type MigratorInterface interface {
+	Version() (int, error)
+	Latest() (int, error)
+	Up() ([]Migration, error)
+	Down(steps int) ([]Migration, error)
+	Force(version int) error
+}
+
+// A Migration is one step in the history of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// The Migrator type applies the embedded migrations to a database. The
+// schema_migrations table holds a row for each migration which has been
+// applied, so the version of the schema is the highest version in it.
+type Migrator struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// The migrations() method reads and checks the migrations for the dialect,
+// in version order.
+func (m *Migrator) migrations() ([]Migration, error) {
+
+	dialect := m.Dialect
+	if dialect == "" {
+		dialect = MySQL
+	}
+	dir := path.Join("migrations", string(dialect))
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrations: unexpected file %s", entry.Name())
+		}
+
+		number, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: no version number in %s", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+
+		if direction == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migrations: version %d is missing", i+1)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migrations: %s needs both an up and a down file", migration.Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Latest returns the version of the newest migration, which is the version
+// of the schema this build of the application expects.
+func (m *Migrator) Latest() (int, error) {
+
+	migrations, err := m.migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(migrations), nil
+}
+
+// The createTable() method creates the schema_migrations table, if it
+// doesn't exist yet.
+func (m *Migrator) createTable() error {
+
+	timestamp := "DATETIME"
+	if m.Dialect == PostgreSQL {
+		timestamp = "TIMESTAMP"
+	}
+
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied ` + timestamp + ` NOT NULL
+	)`)
+	return err
+}
+
+// Version returns the version of the database's schema, which is 0 for a
+// database which has never been migrated.
+func (m *Migrator) Version() (int, error) {
+
+	err := m.createTable()
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+
+	err = m.DB.QueryRow(`SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1`).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		} else {
+			return 0, err
+		}
+	}
+
+	return version, nil
+}
+
+// Check returns an error wrapping ErrSchemaVersion unless the database's
+// schema is at the version this build of the application expects.
+func (m *Migrator) Check() error {
+
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		return err
+	}
+
+	if version != latest {
+		return fmt.Errorf("%w: the database is at version %d, but version %d is needed", ErrSchemaVersion, version, latest)
+	}
+
+	return nil
+}
+
+// Up applies every migration newer than the database's schema, oldest first,
+// and returns the migrations which were applied.
+func (m *Migrator) Up() ([]Migration, error) {
+
+	migrations, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+
+	for _, migration := range migrations[min(version, len(migrations)):] {
+		err = m.apply(migration.Up, `INSERT INTO schema_migrations (version, applied) VALUES(?, ?)`, migration.Version, utcNow())
+		if err != nil {
+			return applied, fmt.Errorf("migrations: %s: %w", migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down undoes the given number of migrations, newest first, and returns the
+// migrations which were undone.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+
+	migrations, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("%w: the database is at version %d, which this build doesn't know how to undo", ErrSchemaVersion, version)
+	}
+
+	var undone []Migration
+
+	for i := version; i > 0 && len(undone) < steps; i-- {
+		migration := migrations[i-1]
+
+		err = m.apply(migration.Down, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+		if err != nil {
+			return undone, fmt.Errorf("migrations: %s: %w", migration.Name, err)
+		}
+		undone = append(undone, migration)
+	}
+
+	return undone, nil
+}
+
+// Force records the database as being at the given version, without running
+// any migrations. It's for databases whose schema was set up by hand, and for
+// tidying up after a migration which failed part way through.
+func (m *Migrator) Force(version int) error {
+
+	latest, err := m.Latest()
+	if err != nil {
+		return err
+	}
+	if version < 0 || version > latest {
+		return fmt.Errorf("migrations: version must be between 0 and %d", latest)
+	}
+
+	err = m.createTable()
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+
+	for v := 1; v <= version; v++ {
+		_, err = tx.Exec(m.Dialect.rebind(`INSERT INTO schema_migrations (version, applied) VALUES(?, ?)`), v, utcNow())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// The apply() method runs the statements in a migration script, followed by
+// the statement which records it in the schema_migrations table, in a
+// transaction. PostgreSQL and SQLite can roll back changes to the schema, but
+// MySQL commits each CREATE, ALTER or DROP statement as soon as it runs, so a
+// MySQL migration which fails part way through has to be tidied up by hand
+// (and then recorded with Force).
+func (m *Migrator) apply(script string, record string, args ...any) error {
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(m.Dialect.rebind(record), args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// The splitStatements() function splits a script into its statements, which
+// end with a semicolon at the end of a line. They're run one at a time,
+// because not every driver will run several statements in one Exec() call
+// (the MySQL driver only does with multiStatements=true in the DSN). Chunks
+// which only hold comments are skipped.
+func splitStatements(script string) []string {
+
+	var stmts []string
+	var stmt strings.Builder
+
+	add := func() {
+		s := strings.TrimSpace(stmt.String())
+		stmt.Reset()
+
+		for _, line := range strings.Split(s, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--") {
+				stmts = append(stmts, s)
+				return
+			}
+		}
+	}
+
+	for _, line := range strings.SplitAfter(script, "\n") {
+		stmt.WriteString(line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			add()
+		}
+	}
+	add()
+
+	return stmts
+}
@@ -1,3 +1,8 @@
+-- The initial schema, as it was before migrations were added. Existing
+-- databases which already have these tables can be marked as being at this
+-- version with "snippetbox migrate force 1", and then brought up to date with
+-- "snippetbox migrate up".
+
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     title VARCHAR(100) NOT NULL,
@@ -17,13 +22,6 @@ CREATE TABLE users (
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
-INSERT INTO users (name, email, hashed_password, created) VALUES (
-    'Alice Jones',
-    'alice@example.com',
-    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-    '2022-01-01 09:18:24'
-);
-
 CREATE TABLE sessions (
     token CHAR(43) PRIMARY KEY,
     data BLOB NOT NULL,
@@ -0,0 +1,14 @@
+-- Each login gets a row in user_sessions, so that users can see where
+-- they're signed in and sign out other devices.
+
+CREATE TABLE user_sessions (
+    id CHAR(43) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token CHAR(43) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
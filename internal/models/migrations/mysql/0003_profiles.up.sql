@@ -0,0 +1,10 @@
+-- Users can pick a handle for their public profile page, which lists the
+-- public snippets they've created. Snippets created before this migration
+-- have no owner.
+
+ALTER TABLE users ADD COLUMN handle VARCHAR(30) NULL;
+ALTER TABLE users ADD CONSTRAINT users_uc_handle UNIQUE (handle);
+
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1,4 @@
+-- This fails if any Argon2id hashes have been stored, because they don't
+-- fit. Those users need their passwords resetting first.
+
+ALTER TABLE users MODIFY COLUMN hashed_password CHAR(60) NOT NULL;
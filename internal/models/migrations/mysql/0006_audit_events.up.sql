@@ -0,0 +1,13 @@
+CREATE TABLE audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    actor_id INTEGER NULL,
+    type VARCHAR(50) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    request_id VARCHAR(32) NOT NULL,
+    details JSON NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id);
+CREATE INDEX audit_events_type_idx ON audit_events (type);
@@ -0,0 +1,6 @@
+DROP INDEX idx_snippets_org_id ON snippets;
+ALTER TABLE snippets DROP COLUMN org_id;
+
+DROP TABLE org_invitations;
+DROP TABLE org_members;
+DROP TABLE orgs;
@@ -0,0 +1,12 @@
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME NULL
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id);
@@ -0,0 +1,29 @@
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    secret CHAR(64) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX webhooks_user_id_idx ON webhooks (user_id);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    attempts INTEGER NOT NULL,
+    status_code INTEGER NOT NULL,
+    error VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id);
+
+CREATE TABLE webhook_expiries (
+    snippet_id INTEGER NOT NULL,
+    expires DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, expires)
+);
@@ -0,0 +1,4 @@
+-- This fails if any Argon2id hashes have been stored, because they don't
+-- fit. Those users need their passwords resetting first.
+
+ALTER TABLE users ALTER COLUMN hashed_password TYPE CHAR(60);
@@ -0,0 +1,5 @@
+-- Argon2id hashes are longer than the 60 characters of a bcrypt hash.
+-- Existing bcrypt hashes are left as they are, and rehashed when their users
+-- next log in.
+
+ALTER TABLE users ALTER COLUMN hashed_password TYPE VARCHAR(255);
@@ -0,0 +1,30 @@
+-- Organisations have members and invitations, and can own snippets which
+-- every member can see.
+
+CREATE TABLE orgs (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE TABLE org_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX org_members_user_id_idx ON org_members (user_id);
+
+CREATE TABLE org_invitations (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY,
+    org_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL
+);
+
+ALTER TABLE snippets ADD COLUMN org_id INTEGER NULL;
+CREATE INDEX idx_snippets_org_id ON snippets(org_id);
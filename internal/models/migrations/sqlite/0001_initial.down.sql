@@ -0,0 +1,3 @@
+DROP TABLE users;
+DROP TABLE snippets;
+DROP TABLE sessions;
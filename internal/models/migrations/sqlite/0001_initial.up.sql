@@ -0,0 +1,31 @@
+-- The initial schema, as it was before migrations were added. Existing
+-- databases which already have these tables can be marked as being at this
+-- version with "snippetbox migrate force 1", and then brought up to date with
+-- "snippetbox migrate up".
+
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+-- The sessions table is laid out the way the scs sqlite3store package
+-- expects, with the expiry time stored as a Julian day number.
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
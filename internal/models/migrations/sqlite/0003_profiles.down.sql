@@ -0,0 +1,6 @@
+DROP INDEX idx_snippets_user_id;
+ALTER TABLE snippets DROP COLUMN visibility;
+ALTER TABLE snippets DROP COLUMN user_id;
+
+DROP INDEX users_uc_handle;
+ALTER TABLE users DROP COLUMN handle;
@@ -0,0 +1,11 @@
+-- Users can pick a handle for their public profile page, which lists the
+-- public snippets they've created. Snippets created before this migration
+-- have no owner. SQLite can't add a column with a UNIQUE constraint, so the
+-- handles are kept unique by an index instead.
+
+ALTER TABLE users ADD COLUMN handle VARCHAR(30) NULL;
+CREATE UNIQUE INDEX users_uc_handle ON users (handle);
+
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1,4 @@
+-- Argon2id hashes are longer than the 60 characters of a bcrypt hash, but
+-- SQLite doesn't enforce the length of a CHAR column, so there's nothing to
+-- change. This migration is only here to keep the versions the same as the
+-- other dialects.
@@ -0,0 +1,4 @@
+-- Every existing user starts as an ordinary, unsuspended user.
+
+ALTER TABLE users ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN suspended BOOLEAN NOT NULL DEFAULT FALSE;
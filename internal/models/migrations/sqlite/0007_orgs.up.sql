@@ -0,0 +1,29 @@
+-- Organisations have members and invitations, and can own snippets which
+-- every member can see.
+
+CREATE TABLE orgs (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE org_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+CREATE INDEX org_members_user_id_idx ON org_members (user_id);
+
+CREATE TABLE org_invitations (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY,
+    org_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+ALTER TABLE snippets ADD COLUMN org_id INTEGER NULL;
+CREATE INDEX idx_snippets_org_id ON snippets(org_id);
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestMigrations(t *testing.T) {
+
+	// Every dialect needs the same migrations, so that a schema version
+	// means the same thing whichever database is used.
+	mysql, err := (&Migrator{Dialect: MySQL}).migrations()
+	assert.NilError(t, err)
+
+	for _, dialect := range []Dialect{PostgreSQL, SQLite} {
+		t.Run(string(dialect), func(t *testing.T) {
+			migrations, err := (&Migrator{Dialect: dialect}).migrations()
+			assert.NilError(t, err)
+			assert.Equal(t, len(migrations), len(mysql))
+
+			for i, migration := range migrations {
+				assert.Equal(t, migration.Name, mysql[i].Name)
+			}
+		})
+	}
+}
+
+func TestMigrationsUpgrade(t *testing.T) {
+
+	db, err := sql.Open(SQLite.DriverName(), SQLite.DriverDSN("sqlite://"+filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := &Migrator{DB: db, Dialect: SQLite}
+
+	migrations, err := m.migrations()
+	assert.NilError(t, err)
+
+	// Set up the schema the way a deployment from before migrations did,
+	// with some data in it.
+	for _, stmt := range splitStatements(migrations[0].Up) {
+		_, err = db.Exec(stmt)
+		assert.NilError(t, err)
+	}
+
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES (
+		'Alice Jones', 'alice@example.com', '$2a$12$EY58Cf5PHn58M1nij3WsxemVQyv7qoZtJGabpK/PsWGWcJfZRWJvS', '2022-01-01 09:18:24')`)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`INSERT INTO snippets (title, content, created, expires) VALUES (
+		'An old snippet', 'Written before migrations', '2022-01-01 09:18:24', '2099-01-01 09:18:24')`)
+	assert.NilError(t, err)
+
+	// Adopting it and then migrating up brings it up to date, keeping the
+	// data.
+	err = m.Force(1)
+	assert.NilError(t, err)
+
+	applied, err := m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(migrations)-1)
+	assert.NilError(t, m.Check())
+
+	user, err := (&UserModel{DB: db, Dialect: SQLite}).Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "alice@example.com")
+	assert.Equal(t, user.Handle, "")
+	assert.Equal(t, user.Role, RoleUser)
+	assert.Equal(t, user.Suspended, false)
+
+	snippet, err := (&SnippetModel{DB: db, Dialect: SQLite}).Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "An old snippet")
+	assert.Equal(t, snippet.UserID, 0)
+	assert.Equal(t, snippet.Visibility, SnippetPublic)
+
+	// Handles added by the migrations are still unique.
+	_, err = db.Exec(`UPDATE users SET handle = 'alice'`)
+	assert.NilError(t, err)
+	_, err = db.Exec(`INSERT INTO users (name, email, handle, hashed_password, created) VALUES (
+		'Bob', 'bob@example.com', 'alice', '', '2022-01-01 09:18:24')`)
+	assert.Equal(t, SQLite.isUniqueViolation(err, "users_uc_handle"), true)
+
+	// And undoing every migration but the first takes it back to where it
+	// started.
+	_, err = m.Down(len(migrations) - 1)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`SELECT handle FROM users`)
+	assert.Equal(t, err != nil, true)
+
+	var title string
+	err = db.QueryRow(`SELECT title FROM snippets`).Scan(&title)
+	assert.NilError(t, err)
+	assert.Equal(t, title, "An old snippet")
+}
+
+func TestSplitStatements(t *testing.T) {
+
+	script := `-- A comment about the table.
+CREATE TABLE orgs (
+    id INTEGER NOT NULL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE INDEX orgs_name_idx ON orgs (name);
+-- A comment at the end.
+`
+
+	stmts := splitStatements(script)
+
+	assert.Equal(t, len(stmts), 2)
+	assert.StringContains(t, stmts[0], "CREATE TABLE orgs (")
+	assert.StringContains(t, stmts[0], "name VARCHAR(100) NOT NULL\n);")
+	assert.Equal(t, stmts[1], "CREATE INDEX orgs_name_idx ON orgs (name);")
+}
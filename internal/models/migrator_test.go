@@ -0,0 +1,65 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestMigrator(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	// newTestDB() has already applied every migration.
+	db := newTestDB(t)
+	m := Migrator{DB: db, Dialect: testDialect}
+
+	latest, err := m.Latest()
+	assert.NilError(t, err)
+
+	version, err := m.Version()
+	assert.NilError(t, err)
+	assert.Equal(t, version, latest)
+	assert.NilError(t, m.Check())
+
+	// Running the migrations again does nothing.
+	applied, err := m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	// Undoing the newest migration leaves the schema out of date.
+	undone, err := m.Down(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(undone), 1)
+	assert.Equal(t, undone[0].Version, latest)
+
+	err = m.Check()
+	assert.Equal(t, errors.Is(err, ErrSchemaVersion), true)
+
+	// Until it's brought up to date again.
+	applied, err = m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 1)
+	assert.NilError(t, m.Check())
+
+	// Forcing a version only changes what's recorded.
+	err = m.Force(0)
+	assert.NilError(t, err)
+
+	version, err = m.Version()
+	assert.NilError(t, err)
+	assert.Equal(t, version, 0)
+
+	err = m.Force(latest)
+	assert.NilError(t, err)
+	assert.NilError(t, m.Check())
+
+	err = m.Force(latest + 1)
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,442 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Every method which reads or changes an organisation takes the ID of the
+// user acting on it, and checks their membership in the same query. That way
+// the checks can't be skipped by a handler (or a future API) which forgets to
+// make them. Organisations which the user isn't a member of are reported as
+// ErrNoRecord, so that their existence isn't leaked.
+type OrgModelInterface interface {
+	Insert(ctx context.Context, name string, ownerID int) (int, error)
+	Get(ctx context.Context, orgID, userID int) (Org, error)
+	ForUser(ctx context.Context, userID int) ([]Org, error)
+	Members(ctx context.Context, orgID, userID int) ([]OrgMember, error)
+	RemoveMember(ctx context.Context, orgID, actorID, userID int) error
+	CreateInvitation(ctx context.Context, orgID, actorID int, role string) (string, error)
+	AcceptInvitation(ctx context.Context, token string, userID int) (int, error)
+	InsertSnippet(ctx context.Context, orgID, userID int, title, content string, expires int) (int, error)
+	Snippet(ctx context.Context, id, userID int) (Snippet, error)
+	DeleteSnippet(ctx context.Context, id, userID int) error
+	Snippets(ctx context.Context, orgID, userID int) ([]Snippet, error)
+}
+
+// The membership roles. Owners can invite and remove members, while members
+// can read the organisation's snippets and add new ones.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleMember = "member"
+)
+
+// invitationLifetime is how long an invitation link can be used for.
+const invitationLifetime = 7 * 24 * time.Hour
+
+// Define an Org type to hold the data for an organisation. Role is the role
+// of the user the organisation was fetched for.
+type Org struct {
+	ID      int
+	Name    string
+	Created time.Time
+	Role    string
+}
+
+// Define an OrgMember type to hold one member of an organisation.
+type OrgMember struct {
+	UserID int
+	Name   string
+	Email  string
+	Role   string
+	Joined time.Time
+}
+
+// Define an OrgModel type which wraps a sql.DB connection pool, and the
+// dialect of the database it's connected to.
+type OrgModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+}
+
+// Insert creates a new organisation, with the given user as its owner, and
+// returns its ID.
+func (m *OrgModel) Insert(ctx context.Context, name string, ownerID int) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	now := utcNow()
+
+	id, err := m.Dialect.insert(ctx, tx, `INSERT INTO orgs (name, created) VALUES(?, ?)`, name, now)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	stmt := `INSERT INTO org_members (org_id, user_id, role, created) VALUES(?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), id, ownerID, OrgRoleOwner, now)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return id, queryError(ctx, tx.Commit())
+}
+
+// Get returns an organisation, if the user is a member of it.
+func (m *OrgModel) Get(ctx context.Context, orgID, userID int) (Org, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT o.id, o.name, o.created, om.role FROM orgs o
+			 INNER JOIN org_members om ON om.org_id = o.id
+			 WHERE o.id = ? AND om.user_id = ?`
+
+	var o Org
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), orgID, userID).Scan(&o.ID, &o.Name, &o.Created, &o.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Org{}, ErrNoRecord
+		} else {
+			return Org{}, queryError(ctx, err)
+		}
+	}
+
+	return o, nil
+}
+
+// ForUser returns all the organisations the user is a member of, by name.
+func (m *OrgModel) ForUser(ctx context.Context, userID int) ([]Org, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT o.id, o.name, o.created, om.role FROM orgs o
+			 INNER JOIN org_members om ON om.org_id = o.id
+			 WHERE om.user_id = ? ORDER BY o.name`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var orgs []Org
+
+	for rows.Next() {
+		var o Org
+		err = rows.Scan(&o.ID, &o.Name, &o.Created, &o.Role)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		orgs = append(orgs, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return orgs, nil
+}
+
+// Members returns the members of an organisation, if the user is one of them.
+func (m *OrgModel) Members(ctx context.Context, orgID, userID int) ([]OrgMember, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	stmt := `SELECT u.id, u.name, u.email, om.role, om.created FROM org_members om
+			 INNER JOIN users u ON u.id = om.user_id
+			 WHERE om.org_id = ? ORDER BY u.name`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), orgID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var members []OrgMember
+
+	for rows.Next() {
+		var om OrgMember
+		err = rows.Scan(&om.UserID, &om.Name, &om.Email, &om.Role, &om.Joined)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		members = append(members, om)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return members, nil
+}
+
+// requireOwner returns ErrNoRecord if the user isn't a member of the
+// organisation, and ErrPermissionDenied if they're a member but not an owner.
+func (m *OrgModel) requireOwner(ctx context.Context, orgID, userID int) error {
+
+	o, err := m.Get(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if o.Role != OrgRoleOwner {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// RemoveMember removes a user from an organisation. Only owners can remove
+// members, and owners can't remove themselves, so that there is always at
+// least one owner left.
+func (m *OrgModel) RemoveMember(ctx context.Context, orgID, actorID, userID int) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.requireOwner(ctx, orgID, actorID)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	if actorID == userID {
+		return ErrPermissionDenied
+	}
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM org_members WHERE org_id = ? AND user_id = ?`), orgID, userID)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// hashInvitationToken returns the value stored in the database for an
+// invitation token. Only the hash is stored, so somebody who can read the
+// database can't use the invitations in it.
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInvitation creates a single-use invitation to join an organisation
+// with the given role, and returns the token to put in the invitation link.
+// Only owners can invite people.
+func (m *OrgModel) CreateInvitation(ctx context.Context, orgID, actorID int, role string) (string, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.requireOwner(ctx, orgID, actorID)
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO org_invitations (token_hash, org_id, role, created_by, created, expires)
+			 VALUES(?, ?, ?, ?, ?, ?)`
+
+	now := utcNow()
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), hashInvitationToken(token), orgID, role, actorID, now, now.Add(invitationLifetime))
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	return token, nil
+}
+
+// AcceptInvitation adds the user to the organisation named in the invitation
+// and uses the invitation up. It returns ErrNoRecord if the token is unknown
+// or has expired. Users who are already members keep their existing role.
+func (m *OrgModel) AcceptInvitation(ctx context.Context, token string, userID int) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT org_id, role FROM org_invitations
+			 WHERE token_hash = ? AND expires > ?` + m.Dialect.forUpdate()
+
+	var orgID int
+	var role string
+
+	err = tx.QueryRowContext(ctx, m.Dialect.rebind(stmt), hashInvitationToken(token), utcNow()).Scan(&orgID, &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, queryError(ctx, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM org_invitations WHERE token_hash = ?`), hashInvitationToken(token))
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	stmt = m.Dialect.insertIgnore(`INTO org_members (org_id, user_id, role, created) VALUES(?, ?, ?, ?)`)
+
+	_, err = tx.ExecContext(ctx, stmt, orgID, userID, role, utcNow())
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	return orgID, queryError(ctx, tx.Commit())
+}
+
+// InsertSnippet adds a snippet owned by the organisation, with the user as
+// its author. The membership check is part of the INSERT statement, so that
+// there is no window between checking and inserting.
+func (m *OrgModel) InsertSnippet(ctx context.Context, orgID, userID int, title, content string, expires int) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `INSERT INTO snippets (user_id, org_id, title, content, created, expires, visibility)
+			 SELECT ?, org_id, ?, ?, ?, ?, ?
+			 FROM org_members WHERE org_id = ? AND user_id = ?`
+
+	created := utcNow()
+
+	// If the user isn't a member, nothing is inserted and insert() returns
+	// ErrNoRecord.
+	return m.Dialect.insert(ctx, m.DB, stmt, userID, title, content, created, created.AddDate(0, 0, expires), SnippetPrivate, orgID, userID)
+}
+
+// Snippet returns an organisation-owned snippet, if the user is a member of
+// the organisation which owns it.
+func (m *OrgModel) Snippet(ctx context.Context, id, userID int) (Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+			 WHERE expires > ? AND id = ?
+			 AND org_id IN (SELECT org_id FROM org_members WHERE user_id = ?)`
+
+	s, err := scanSnippet(m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), utcNow(), id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		} else {
+			return Snippet{}, queryError(ctx, err)
+		}
+	}
+
+	return s, nil
+}
+
+// DeleteSnippet deletes an organisation-owned snippet. Owners can delete any
+// of the organisation's snippets, and members only the ones they wrote. The
+// checks are part of the DELETE statement, like in InsertSnippet(). It returns
+// ErrNoRecord if the user can't see the snippet, and ErrPermissionDenied if
+// they can but aren't allowed to delete it.
+func (m *OrgModel) DeleteSnippet(ctx context.Context, id, userID int) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE id = ?
+			 AND org_id IN (SELECT org_id FROM org_members
+			                WHERE user_id = ? AND (role = ? OR user_id = snippets.user_id))`
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), id, userID, OrgRoleOwner)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	if rows == 0 {
+		_, err = m.Snippet(ctx, id, userID)
+		if err != nil {
+			return err
+		}
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// Snippets returns an organisation's unexpired snippets, newest first, if the
+// user is a member of it.
+func (m *OrgModel) Snippets(ctx context.Context, orgID, userID int) ([]Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	_, err := m.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+			 WHERE org_id = ? AND expires > ? ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), orgID, utcNow())
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return snippets, nil
+}
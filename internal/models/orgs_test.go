@@ -0,0 +1,101 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/password"
+)
+
+func TestOrgModelMembership(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := OrgModel{DB: db, Dialect: testDialect}
+
+	users := UserModel{DB: db, Dialect: testDialect, Hasher: &password.Bcrypt{Cost: 4}}
+	err := users.Insert(t.Context(), "Bob", "bob@example.com", "", "pa$$word")
+	assert.NilError(t, err)
+
+	// Alice (user 1) creates the organisation and a snippet in it.
+	orgID, err := m.Insert(t.Context(), "Acme", 1)
+	assert.NilError(t, err)
+
+	snippetID, err := m.InsertSnippet(t.Context(), orgID, 1, "Title", "Content", 7)
+	assert.NilError(t, err)
+
+	// Bob (user 2) can't see anything, or add snippets, until he joins.
+	_, err = m.Get(t.Context(), orgID, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	_, err = m.Snippet(t.Context(), snippetID, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	_, err = m.InsertSnippet(t.Context(), orgID, 2, "Title", "Content", 7)
+	assert.Equal(t, err, ErrNoRecord)
+
+	_, err = m.CreateInvitation(t.Context(), orgID, 2, OrgRoleMember)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Organisation snippets are never returned by SnippetModel.Get().
+	_, err = (&SnippetModel{DB: db, Dialect: testDialect}).Get(t.Context(), snippetID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	token, err := m.CreateInvitation(t.Context(), orgID, 1, OrgRoleMember)
+	assert.NilError(t, err)
+
+	joined, err := m.AcceptInvitation(t.Context(), token, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, joined, orgID)
+
+	// Invitations can only be used once.
+	_, err = m.AcceptInvitation(t.Context(), token, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	s, err := m.Snippet(t.Context(), snippetID, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, s.OrgID, orgID)
+
+	// Organisation snippets can't be deleted with SnippetModel.Delete().
+	err = (&SnippetModel{DB: db, Dialect: testDialect}).Delete(t.Context(), snippetID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Members can only delete the snippets they wrote, and owners any of
+	// them.
+	err = m.DeleteSnippet(t.Context(), snippetID, 2)
+	assert.Equal(t, err, ErrPermissionDenied)
+
+	bobsID, err := m.InsertSnippet(t.Context(), orgID, 2, "Title", "Content", 7)
+	assert.NilError(t, err)
+
+	err = m.DeleteSnippet(t.Context(), bobsID, 2)
+	assert.NilError(t, err)
+
+	err = m.DeleteSnippet(t.Context(), bobsID, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.DeleteSnippet(t.Context(), snippetID, 1)
+	assert.NilError(t, err)
+
+	_, err = m.Snippet(t.Context(), snippetID, 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Members can't invite people or remove other members.
+	_, err = m.CreateInvitation(t.Context(), orgID, 2, OrgRoleMember)
+	assert.Equal(t, err, ErrPermissionDenied)
+
+	err = m.RemoveMember(t.Context(), orgID, 2, 1)
+	assert.Equal(t, err, ErrPermissionDenied)
+
+	err = m.RemoveMember(t.Context(), orgID, 1, 2)
+	assert.NilError(t, err)
+
+	_, err = m.Snippets(t.Context(), orgID, 2)
+	assert.Equal(t, err, ErrNoRecord)
+}
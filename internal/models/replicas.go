@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The Replicas type holds connection pools for read replicas of the primary
+// database. Models with a Replicas field send some of their reads to a
+// replica, taking turns between the replicas which passed their last health
+// check. If none of them did, the reads go to the primary instead.
+//
+// Replicas lag a little behind the primary, so a read which has to see a
+// write made just before it should use a context made by WithPrimary().
+//
+// A nil *Replicas is valid, and always picks the primary.
+type Replicas struct {
+	logger   *slog.Logger
+	replicas []*replica
+	next     atomic.Uint64
+
+	// allDown records whether reads are falling back to the primary, so
+	// that it's only logged when it starts and stops.
+	allDown atomic.Bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// The replica type is a single read replica. It's considered unhealthy until
+// its first successful health check.
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+	checked bool // Only used by check(), which never runs concurrently.
+}
+
+// NewReplicas returns a set of read replicas using the given connection
+// pools. The replicas are named "replica 1", "replica 2" and so on in the
+// logs, so that the DSNs (and their passwords) don't end up there.
+func NewReplicas(logger *slog.Logger, dbs ...*sql.DB) *Replicas {
+
+	r := &Replicas{logger: logger, stop: make(chan struct{})}
+
+	for i, db := range dbs {
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica %d", i+1), db: db})
+	}
+
+	return r
+}
+
+// Start checks the health of every replica, and then keeps checking them
+// in the background at the given interval until Close() is called. Each
+// check is a ping which has to answer within the timeout.
+func (r *Replicas) Start(interval, timeout time.Duration) {
+
+	r.check(timeout)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.check(timeout)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the health checks and closes the replicas' connection pools.
+// It doesn't close the primary, which belongs to the caller.
+func (r *Replicas) Close() error {
+
+	close(r.stop)
+	r.wg.Wait()
+
+	var err error
+	for _, rep := range r.replicas {
+		if closeErr := rep.db.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// The check() method pings every replica, and logs any replica whose health
+// has changed since the last check, or which failed its first check.
+func (r *Replicas) check(timeout time.Duration) {
+
+	anyHealthy := false
+
+	for _, rep := range r.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := rep.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy || !rep.checked {
+			if healthy {
+				r.logger.Info("read replica is healthy", "replica", rep.name)
+			} else {
+				r.logger.Warn("read replica is unhealthy", "replica", rep.name, "err", err)
+			}
+		}
+
+		rep.checked = true
+		anyHealthy = anyHealthy || healthy
+	}
+
+	if anyHealthy && r.allDown.CompareAndSwap(true, false) {
+		r.logger.Info("read replicas are back, reads have stopped falling back to the primary")
+	}
+}
+
+// The reader() method returns the connection pool a read should use: the
+// next healthy replica in turn, or the primary if the context was made by
+// WithPrimary() or none of the replicas are healthy.
+func (r *Replicas) reader(ctx context.Context, primary *sql.DB) *sql.DB {
+
+	if r == nil || len(r.replicas) == 0 || UsingPrimary(ctx) {
+		return primary
+	}
+
+	start := r.next.Add(1)
+	for i := range uint64(len(r.replicas)) {
+		rep := r.replicas[(start+i)%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+
+	if r.allDown.CompareAndSwap(false, true) {
+		r.logger.Error("all read replicas are down, falling back to the primary")
+	}
+
+	return primary
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+// The newReplicaTestDB() helper returns a migrated SQLite database holding
+// one snippet, whose title identifies the database.
+func newReplicaTestDB(t *testing.T, title string) *sql.DB {
+
+	db, err := sql.Open(SQLite.DriverName(), SQLite.DriverDSN("sqlite://"+filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = (&Migrator{DB: db, Dialect: SQLite}).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = (&SnippetModel{DB: db, Dialect: SQLite}).Insert(t.Context(), 1, title, "", 7, SnippetPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestReplicas(t *testing.T) {
+
+	primary := newReplicaTestDB(t, "Primary")
+	replica1 := newReplicaTestDB(t, "Replica 1")
+	replica2 := newReplicaTestDB(t, "Replica 2")
+
+	var logs bytes.Buffer
+	replicas := NewReplicas(slog.New(slog.NewTextHandler(&logs, nil)), replica1, replica2)
+	replicas.Start(time.Hour, time.Second)
+
+	m := &SnippetModel{DB: primary, Dialect: SQLite, Replicas: replicas}
+
+	title := func(usePrimary bool) string {
+		t.Helper()
+		ctx := t.Context()
+		if usePrimary {
+			ctx = WithPrimary(ctx)
+		}
+		s, err := m.Get(ctx, 1)
+		assert.NilError(t, err)
+		return s.Title
+	}
+
+	// Reads take turns between the replicas.
+	seen := map[string]int{}
+	for range 4 {
+		seen[title(false)]++
+	}
+	assert.Equal(t, seen["Replica 1"], 2)
+	assert.Equal(t, seen["Replica 2"], 2)
+
+	// Unless they have to see the primary.
+	assert.Equal(t, title(true), "Primary")
+
+	latest, err := m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].Title != "Primary", true)
+
+	// An unhealthy replica is skipped.
+	replica1.Close()
+	replicas.check(time.Second)
+	assert.StringContains(t, logs.String(), "read replica is unhealthy")
+
+	for range 2 {
+		assert.Equal(t, title(false), "Replica 2")
+	}
+
+	// With no healthy replicas, reads fall back to the primary, which is
+	// logged.
+	replica2.Close()
+	replicas.check(time.Second)
+
+	for range 2 {
+		assert.Equal(t, title(false), "Primary")
+	}
+	assert.StringContains(t, logs.String(), "all read replicas are down")
+
+	replicas.Close()
+}
+
+func TestReplicasUpdate(t *testing.T) {
+
+	primary := newReplicaTestDB(t, "Primary")
+
+	// The replica has no snippets, so the existence check in Update() would
+	// fail if it read from the replica.
+	replica, err := sql.Open(SQLite.DriverName(), SQLite.DriverDSN("sqlite://"+filepath.Join(t.TempDir(), "empty.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&Migrator{DB: replica, Dialect: SQLite}).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replicas := NewReplicas(slog.New(slog.DiscardHandler), replica)
+	replicas.Start(time.Hour, time.Second)
+	defer replicas.Close()
+
+	m := &SnippetModel{DB: primary, Dialect: SQLite, Replicas: replicas}
+
+	_, err = m.Get(t.Context(), 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.Update(t.Context(), 1, "Updated", "", 7, SnippetPublic)
+	assert.NilError(t, err)
+
+	s, err := m.Get(WithPrimary(t.Context()), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Updated")
+}
+
+func TestReplicasNil(t *testing.T) {
+
+	var replicas *Replicas
+	primary := &sql.DB{}
+
+	assert.Equal(t, replicas.reader(t.Context(), primary), primary)
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The CachedSnippetModel type wraps another SnippetModelInterface and keeps
+// the results of Get() and Latest() in memory, so that a popular snippet (or
+// the home page) doesn't cost a database query on every request. It's a
+// least-recently-used cache with a maximum number of entries, and each entry
+// is kept for at most the TTL, or until the snippet expires if that's sooner.
+// It has to be created with NewCachedSnippetModel().
+//
+// The entries are always read from the primary database, even when the
+// wrapped model has read replicas, because a replica which hasn't caught up
+// with a change yet would put the old version back in the cache for the whole
+// TTL. A read with a context made by WithPrimary() skips the cache
+// altogether.
+//
+// Insert(), Update() and Delete() throw away the entries they affect, but the
+// cache only knows about changes made through it. Changes made any other way
+// (by UserModel.Delete(), or by another instance of the application sharing
+// the same database) are only seen once the entries reach the end of their
+// TTL, so the TTL should be kept short.
+type CachedSnippetModel struct {
+	model SnippetModelInterface
+	size  int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	order   *list.List // Most recently used at the front.
+	hits    uint64
+	misses  uint64
+
+	// generation is incremented by every change, so that a result which was
+	// read from the database before the change isn't cached after it.
+	generation uint64
+
+	// now returns the current time. The tests replace it to move the clock
+	// forward.
+	now func() time.Time
+}
+
+// The cacheKey type identifies an entry in the cache: either the result of
+// Latest(), or the result of Get() for a snippet ID.
+type cacheKey struct {
+	latest bool
+	id     int
+}
+
+// The cacheEntry type holds a cached result, and the time it stops being
+// valid.
+type cacheEntry struct {
+	key      cacheKey
+	snippets []Snippet
+	expires  time.Time
+}
+
+// SnippetCacheStats holds the counters for a CachedSnippetModel.
+type SnippetCacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// NewCachedSnippetModel returns a cache of up to size entries in front of
+// the given model.
+func NewCachedSnippetModel(model SnippetModelInterface, size int, ttl time.Duration) *CachedSnippetModel {
+	return &CachedSnippetModel{
+		model:   model,
+		size:    size,
+		ttl:     ttl,
+		entries: map[cacheKey]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns a snippet from the cache, or reads it from the wrapped model
+// and caches it. Errors, including ErrNoRecord, aren't cached.
+func (m *CachedSnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+
+	key := cacheKey{id: id}
+
+	snippets, generation, ok := m.lookup(ctx, key)
+	if ok {
+		return snippets[0], nil
+	}
+
+	s, err := m.model.Get(WithPrimary(ctx), id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	m.store(key, []Snippet{s}, generation)
+
+	return s, nil
+}
+
+// Latest returns the latest snippets from the cache, or reads them from the
+// wrapped model and caches them. The entry is only kept until the first of
+// the snippets expires, as it would have to drop out of the list then.
+func (m *CachedSnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+
+	key := cacheKey{latest: true}
+
+	snippets, generation, ok := m.lookup(ctx, key)
+	if ok {
+		return snippets, nil
+	}
+
+	snippets, err := m.model.Latest(WithPrimary(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	m.store(key, snippets, generation)
+
+	return snippets, nil
+}
+
+// Insert adds a snippet through the wrapped model. A new snippet may belong
+// in the latest snippets, so that entry is thrown away.
+func (m *CachedSnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int, visibility string) (int, error) {
+
+	id, err := m.model.Insert(ctx, userID, title, content, expires, visibility)
+
+	m.invalidate(cacheKey{latest: true})
+
+	return id, err
+}
+
+// Update changes a snippet through the wrapped model, and throws away the
+// cached copies of it. The entries are thrown away even if the update fails,
+// as it may have reached the database before the error.
+func (m *CachedSnippetModel) Update(ctx context.Context, id int, title, content string, expires int, visibility string) error {
+
+	err := m.model.Update(ctx, id, title, content, expires, visibility)
+
+	m.invalidate(cacheKey{id: id}, cacheKey{latest: true})
+
+	return err
+}
+
+// Delete removes a snippet through the wrapped model, and throws away the
+// cached copies of it.
+func (m *CachedSnippetModel) Delete(ctx context.Context, id int) error {
+
+	err := m.model.Delete(ctx, id)
+
+	m.invalidate(cacheKey{id: id}, cacheKey{latest: true})
+
+	return err
+}
+
+// ForUser, Recent and CountLive aren't cached, as they're paginated, only
+// used by admins, or both.
+func (m *CachedSnippetModel) ForUser(ctx context.Context, userID, page, pageSize int) ([]Snippet, bool, error) {
+	return m.model.ForUser(ctx, userID, page, pageSize)
+}
+
+func (m *CachedSnippetModel) Recent(ctx context.Context, limit int) ([]Snippet, error) {
+	return m.model.Recent(ctx, limit)
+}
+
+func (m *CachedSnippetModel) CountLive(ctx context.Context) (int, error) {
+	return m.model.CountLive(ctx)
+}
+
+// Purge throws away every entry in the cache. It's for changes which the
+// cache can't see, such as a user being deleted along with their snippets.
+func (m *CachedSnippetModel) Purge() {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generation++
+	clear(m.entries)
+	m.order.Init()
+}
+
+// Stats returns the cache's counters.
+func (m *CachedSnippetModel) Stats() SnippetCacheStats {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return SnippetCacheStats{Hits: m.hits, Misses: m.misses, Entries: len(m.entries)}
+}
+
+// The lookup() method returns a copy of the snippets in an unexpired entry,
+// and counts the hit or miss. On a miss it also returns the current
+// generation, to be passed on to store(). A context made by WithPrimary()
+// always misses, as the entry may be missing a change which the cache didn't
+// see.
+func (m *CachedSnippetModel) lookup(ctx context.Context, key cacheKey) ([]Snippet, uint64, bool) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok && !UsingPrimary(ctx) {
+		entry := e.Value.(*cacheEntry)
+		if m.now().Before(entry.expires) {
+			m.hits++
+			m.order.MoveToFront(e)
+			return slices.Clone(entry.snippets), 0, true
+		}
+		m.remove(e)
+	}
+
+	m.misses++
+
+	return nil, m.generation, false
+}
+
+// The store() method adds an entry to the cache, unless something has
+// changed since the snippets were read, in which case they may be out of
+// date. The least recently used entry is evicted if the cache is full.
+func (m *CachedSnippetModel) store(key cacheKey, snippets []Snippet, generation uint64) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if generation != m.generation || m.size < 1 {
+		return
+	}
+
+	expires := m.now().Add(m.ttl)
+	for _, s := range snippets {
+		if s.Expires.Before(expires) {
+			expires = s.Expires
+		}
+	}
+	if !m.now().Before(expires) {
+		return
+	}
+
+	if e, ok := m.entries[key]; ok {
+		m.remove(e)
+	}
+
+	m.entries[key] = m.order.PushFront(&cacheEntry{key: key, snippets: slices.Clone(snippets), expires: expires})
+
+	for m.order.Len() > m.size {
+		m.remove(m.order.Back())
+	}
+}
+
+// The invalidate() method throws away the given entries.
+func (m *CachedSnippetModel) invalidate(keys ...cacheKey) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generation++
+	for _, key := range keys {
+		if e, ok := m.entries[key]; ok {
+			m.remove(e)
+		}
+	}
+}
+
+// The remove() method removes an entry. The caller must hold the lock.
+func (m *CachedSnippetModel) remove(e *list.Element) {
+	m.order.Remove(e)
+	delete(m.entries, e.Value.(*cacheEntry).key)
+}
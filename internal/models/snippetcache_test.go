@@ -0,0 +1,294 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+// The fakeSnippetModel type stands in for the database behind a
+// CachedSnippetModel, and counts the reads which reach it, and which of them
+// could have gone to a read replica.
+type fakeSnippetModel struct {
+	snippets     map[int]Snippet
+	gets         int
+	latests      int
+	replicaReads int
+}
+
+func (m *fakeSnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int, visibility string) (int, error) {
+	id := len(m.snippets) + 1
+	m.snippets[id] = Snippet{ID: id, Title: title, Expires: time.Now().AddDate(0, 0, expires)}
+	return id, nil
+}
+
+func (m *fakeSnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	m.gets++
+	if !UsingPrimary(ctx) {
+		m.replicaReads++
+	}
+	s, ok := m.snippets[id]
+	if !ok {
+		return Snippet{}, ErrNoRecord
+	}
+	return s, nil
+}
+
+func (m *fakeSnippetModel) Update(ctx context.Context, id int, title, content string, expires int, visibility string) error {
+	s := m.snippets[id]
+	s.Title = title
+	m.snippets[id] = s
+	return nil
+}
+
+func (m *fakeSnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	m.latests++
+	if !UsingPrimary(ctx) {
+		m.replicaReads++
+	}
+	var snippets []Snippet
+	for id := len(m.snippets) + 1; id > 0; id-- {
+		if s, ok := m.snippets[id]; ok {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, nil
+}
+
+func (m *fakeSnippetModel) ForUser(ctx context.Context, userID, page, pageSize int) ([]Snippet, bool, error) {
+	return nil, false, nil
+}
+
+func (m *fakeSnippetModel) Recent(ctx context.Context, limit int) ([]Snippet, error) {
+	return nil, nil
+}
+
+func (m *fakeSnippetModel) Delete(ctx context.Context, id int) error {
+	delete(m.snippets, id)
+	return nil
+}
+
+func (m *fakeSnippetModel) CountLive(ctx context.Context) (int, error) {
+	return len(m.snippets), nil
+}
+
+func newFakeSnippetModel() *fakeSnippetModel {
+	expires := time.Now().Add(time.Hour)
+	return &fakeSnippetModel{snippets: map[int]Snippet{
+		1: {ID: 1, Title: "One", Expires: expires},
+		2: {ID: 2, Title: "Two", Expires: expires},
+		3: {ID: 3, Title: "Three", Expires: expires},
+	}}
+}
+
+func TestCachedSnippetModelGet(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 10, time.Minute)
+
+	for range 3 {
+		s, err := m.Get(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "One")
+	}
+
+	assert.Equal(t, fake.gets, 1)
+	assert.Equal(t, m.Stats(), SnippetCacheStats{Hits: 2, Misses: 1, Entries: 1})
+
+	// Missing snippets aren't cached.
+	for range 2 {
+		_, err := m.Get(t.Context(), 99)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	}
+	assert.Equal(t, fake.gets, 3)
+}
+
+func TestCachedSnippetModelExpiry(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 10, time.Minute)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// Snippet 2 expires before the end of the TTL, so it's only cached until
+	// then.
+	s := fake.snippets[2]
+	s.Expires = now.Add(10 * time.Second)
+	fake.snippets[2] = s
+
+	m.Get(t.Context(), 1)
+	m.Get(t.Context(), 2)
+	assert.Equal(t, fake.gets, 2)
+
+	now = now.Add(30 * time.Second)
+	m.Get(t.Context(), 1)
+	m.Get(t.Context(), 2)
+	assert.Equal(t, fake.gets, 3)
+
+	now = now.Add(time.Minute)
+	m.Get(t.Context(), 1)
+	assert.Equal(t, fake.gets, 4)
+
+	// The latest snippets are only cached until the first of them expires.
+	m.Latest(t.Context())
+	m.Latest(t.Context())
+	assert.Equal(t, fake.latests, 2)
+}
+
+func TestCachedSnippetModelInvalidation(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 10, time.Minute)
+
+	m.Get(t.Context(), 1)
+	m.Latest(t.Context())
+	m.Latest(t.Context())
+	assert.Equal(t, fake.latests, 1)
+
+	err := m.Update(t.Context(), 1, "Uno", "", 7, SnippetPublic)
+	assert.NilError(t, err)
+
+	s, err := m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Uno")
+
+	latest, err := m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[2].Title, "Uno")
+
+	id, err := m.Insert(t.Context(), 1, "Four", "", 7, SnippetPublic)
+	assert.NilError(t, err)
+
+	latest, err = m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].ID, id)
+
+	err = m.Delete(t.Context(), 1)
+	assert.NilError(t, err)
+
+	_, err = m.Get(t.Context(), 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	latest, err = m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 3)
+
+	m.Get(t.Context(), 2)
+	m.Purge()
+	assert.Equal(t, m.Stats().Entries, 0)
+}
+
+func TestCachedSnippetModelPrimary(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 10, time.Minute)
+
+	m.Get(t.Context(), 1)
+	m.Latest(t.Context())
+
+	// A change the cache doesn't see, made by another instance of the
+	// application.
+	fake.snippets[1] = Snippet{ID: 1, Title: "Uno", Expires: time.Now().Add(time.Hour)}
+
+	s, err := m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "One")
+
+	// Reads which have to see the primary skip the cache...
+	s, err = m.Get(WithPrimary(t.Context()), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Uno")
+	assert.Equal(t, fake.gets, 2)
+
+	// ...and refresh it.
+	s, err = m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Uno")
+	assert.Equal(t, fake.gets, 2)
+
+	latest, err := m.Latest(WithPrimary(t.Context()))
+	assert.NilError(t, err)
+	assert.Equal(t, latest[2].Title, "Uno")
+	assert.Equal(t, fake.latests, 2)
+
+	// The cache only ever reads from the primary.
+	assert.Equal(t, fake.replicaReads, 0)
+}
+
+func TestCachedSnippetModelReplicas(t *testing.T) {
+
+	// The replica is lagging behind: it still has the snippet's old title,
+	// which the primary has already replaced.
+	primary := newReplicaTestDB(t, "Old title")
+	replica := newReplicaTestDB(t, "Old title")
+
+	replicas := NewReplicas(slog.New(slog.DiscardHandler), replica)
+	replicas.Start(time.Hour, time.Second)
+	defer replicas.Close()
+
+	model := &SnippetModel{DB: primary, Dialect: SQLite, Replicas: replicas}
+	m := NewCachedSnippetModel(model, 10, time.Minute)
+
+	_, err := m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+
+	err = m.Update(t.Context(), 1, "New title", "", 7, SnippetPublic)
+	assert.NilError(t, err)
+
+	s, err := model.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Old title")
+
+	// The next visitor's cache miss mustn't put the replica's old copy in
+	// the cache, where the editor would find it.
+	s, err = m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "New title")
+
+	s, err = m.Get(WithPrimary(t.Context()), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "New title")
+
+	latest, err := m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].Title, "New title")
+}
+
+func TestCachedSnippetModelEviction(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 2, time.Minute)
+
+	m.Get(t.Context(), 1)
+	m.Get(t.Context(), 2)
+	m.Get(t.Context(), 1) // Snippet 2 is now the least recently used.
+	m.Get(t.Context(), 3)
+	assert.Equal(t, fake.gets, 3)
+	assert.Equal(t, m.Stats().Entries, 2)
+
+	m.Get(t.Context(), 1)
+	assert.Equal(t, fake.gets, 3)
+
+	m.Get(t.Context(), 2)
+	assert.Equal(t, fake.gets, 4)
+}
+
+func TestCachedSnippetModelCopies(t *testing.T) {
+
+	fake := newFakeSnippetModel()
+	m := NewCachedSnippetModel(fake, 10, time.Minute)
+
+	// Changing a slice returned by the cache mustn't change the cached copy.
+	latest, err := m.Latest(t.Context())
+	assert.NilError(t, err)
+	latest[0].Title = "Changed"
+
+	latest, err = m.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].Title, "Three")
+}
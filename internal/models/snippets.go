@@ -1,17 +1,33 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (Snippet, error)
-	Latest() ([]Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, expires int, visibility string) (int, error)
+	Get(ctx context.Context, id int) (Snippet, error)
+	Update(ctx context.Context, id int, title string, content string, expires int, visibility string) error
+	Latest(ctx context.Context) ([]Snippet, error)
+	ForUser(ctx context.Context, userID, page, pageSize int) ([]Snippet, bool, error)
+	Recent(ctx context.Context, limit int) ([]Snippet, error)
+	Delete(ctx context.Context, id int) error
+	CountLive(ctx context.Context) (int, error)
 }
 
+// The visibility of a snippet controls where it can be seen. Public snippets
+// are listed on the home page and the owner's profile, unlisted snippets can
+// only be reached by someone who has the link, and private snippets can only
+// be viewed by their owner.
+const (
+	SnippetPublic   = "public"
+	SnippetUnlisted = "unlisted"
+	SnippetPrivate  = "private"
+)
+
 // Remember: The internal directory is being used to hold ancillary non-application-
 // specific code, which could potentially be reused. A database model which could be
 // used by other applications in the future (like a command line interface application) fits
@@ -19,75 +35,93 @@ type SnippetModelInterface interface {
 
 // Define a Snippet type to hold the data for an individual snippet. Notice
 // how fields of the struct correspond to the fields in mysql snippets
-// table ?
+// table ? The struct tags control how snippets are represented by the JSON
+// API, so that clients can decode them back into this same type.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id,omitempty"` // Zero if the snippet has no owner.
+	OrgID      int       `json:"org_id,omitempty"`  // Zero unless the snippet is owned by an organisation.
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+}
+
+// The snippetColumns constant and scanSnippet function keep the list of
+// selected columns and the fields they are scanned into in one place.
+const snippetColumns = `id, user_id, org_id, title, content, created, expires, visibility`
+
+func scanSnippet(row interface{ Scan(...any) error }) (Snippet, error) {
+	var s Snippet
+	var userID, orgID sql.NullInt64
+
+	err := row.Scan(&s.ID, &userID, &orgID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Visibility)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	s.UserID = int(userID.Int64)
+	s.OrgID = int(orgID.Int64)
+
+	return s, nil
 }
 
-// Define a SnippetModel type which wraps a sql.DB connection pool.
+// Define a SnippetModel type which wraps a sql.DB connection pool, and the
+// dialect of the database it's connected to. If Replicas is set, Get() and
+// Latest() read from the read replicas.
 type SnippetModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+	Replicas     *Replicas     // Nil if there are no read replicas.
 }
 
 // This will insert a new snippet into database.
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int, visibility string) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
 
 	// Write the SQL stmt we want to execute. it's splitted to two lines
 	// for readability.
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-			VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL stmt, followed by
-	// values for the placeholder params. This method returns a sql.Result type which contains some
-	// basic information bout what happened when the was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
-	}
+	stmt := `INSERT INTO snippets (user_id, title, content, created, expires, visibility)
+			VALUES(?, ?, ?, ?, ?, ?)`
 
-	// Use the LastInsertId() method on the result to get the ID of newly
-	// inserted record in the snippets table.
+	// The created and expires times are worked out here rather than in SQL,
+	// because every database has its own date functions.
+	created := utcNow()
+	expiresAt := created.AddDate(0, 0, expires)
+
+	// Use the dialect's insert() helper to execute the statement and get the
+	// ID of the newly inserted record in the snippets table.
 	// 	Important: Not all drivers and databases support the LastInsertId() and
 	// RowsAffected() methods. For example, LastInsertId() is not supported by
-	// PostgreSQL. So if you’re planning on using these methods it’s important to check the
-	// documentation for your particular driver first.
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-
-	// The ID returned has the type int64, so convert it to int type
-	// before returning.
-	return int(id), nil
+	// PostgreSQL, so insert() uses a RETURNING clause there instead.
+	return m.Dialect.insert(ctx, m.DB, stmt, userID, title, content, created, expiresAt, visibility)
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+// This will return a specific snippet based on its id. Snippets owned by an
+// organisation are never returned, as they can only be read through the
+// OrgModel, which checks that the reader is a member.
+func (m *SnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
 
 	// Write the SQL stmt we wanted to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-			 WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+			 WHERE expires > ? AND id = ? AND org_id IS NULL`
 
 	//  Use the QueryRow() method on the connection pool to execute the
 	// SQL stmt, passing int the untrusted id variable as the value for the
 	// placeholder parameter. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
-
-	// Initialize a new zeroed Snippet struct.
-	var s Snippet
+	row := m.Replicas.reader(ctx, m.DB).QueryRowContext(ctx, m.Dialect.rebind(stmt), utcNow(), id)
 
-	// Use row.Scan() to copy the values from each fields in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that arguments
-	// to row.Scan are *pointers* to the place u want to copy the data into,
-	// and the number of args must be exactly the same as the number of the
-	// columns returned by ur stmmt.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Use scanSnippet() to copy the values from each fields in sql.Row to the
+	// corresponding field in a new Snippet struct.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function to check for that
@@ -97,7 +131,7 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
 		} else {
-			return Snippet{}, err
+			return Snippet{}, queryError(ctx, err)
 		}
 	}
 
@@ -105,19 +139,54 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 	return s, nil
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]Snippet, error) {
+// This will update the title, content and visibility of a snippet, and reset
+// its expiry time to the given number of days from now, unless expires is
+// zero. It returns ErrNoRecord if there is no unexpired snippet with the given
+// ID.
+func (m *SnippetModel) Update(ctx context.Context, id int, title, content string, expires int, visibility string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Check that the snippet exists first, because MySQL's RowsAffected()
+	// doesn't count rows which matched but were left unchanged. This has to
+	// be checked on the primary, which is where the UPDATE will run.
+	_, err := m.Get(WithPrimary(ctx), id)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	// An expires of zero (or less) leaves the expiry time as it is.
+	if expires <= 0 {
+		stmt := `UPDATE snippets SET title = ?, content = ?, visibility = ? WHERE id = ?`
+
+		_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), title, content, visibility, id)
+		return queryError(ctx, err)
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, visibility = ?, expires = ?
+			 WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), title, content, visibility, utcNow().AddDate(0, 0, expires), id)
+	return queryError(ctx, err)
+}
+
+// This will return the 10 most recently created public snippets.
+func (m *SnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
 
 	// Write the SQL stmt ...
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-			 WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+			 WHERE expires > ? AND visibility = 'public' ORDER BY id DESC LIMIT 10`
 
 	// Use the Query() method on the conn pool to execute sql stmt
 	// This returns a sql.Rows resultset containing the result of
 	// our query.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.Replicas.reader(ctx, m.DB).QueryContext(ctx, m.Dialect.rebind(stmt), utcNow())
 	if err != nil {
-		return nil, err
+		return nil, queryError(ctx, err)
 	}
 
 	// We defer rows.Close() to ensure the sql.Rows resultset is
@@ -137,15 +206,11 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// db conn.
 	for rows.Next() {
 
-		// Create a new zeroed Snippet struct.
-		var s Snippet
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the args to row.Scan()
-		// must be pointers to the place u want to copy the data into, and the
-		// columns returned by your stmt.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// Use scanSnippet() to copy the values from each field in the row to a
+		// new Snippet object.
+		s, err := scanSnippet(rows)
 		if err != nil {
-			return nil, err
+			return nil, queryError(ctx, err)
 		}
 		// Append it to the slice of snippets.
 		snippets = append(snippets, s)
@@ -157,9 +222,119 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// call this - don't assume that a successful iteration was completed
 	// over the whole resultset
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, queryError(ctx, err)
 	}
 
 	// If everything went OK then return the Snippets slice
 	return snippets, nil
 }
+
+// This will return one page of a user's public, non-expired snippets, newest
+// first. Pages are numbered from 1. The boolean result reports whether there
+// is another page after this one.
+func (m *SnippetModel) ForUser(ctx context.Context, userID, page, pageSize int) ([]Snippet, bool, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Fetch one extra row so that we know whether there is a next page
+	// without needing a separate COUNT(*) query.
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+			 WHERE user_id = ? AND visibility = 'public' AND expires > ?
+			 ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID, utcNow(), pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return nil, false, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, false, queryError(ctx, err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, false, queryError(ctx, err)
+	}
+
+	if len(snippets) > pageSize {
+		return snippets[:pageSize], true, nil
+	}
+
+	return snippets, false, nil
+}
+
+// This will return the most recently created snippets, whatever their
+// visibility and including expired ones. It's intended for moderation only.
+func (m *SnippetModel) Recent(ctx context.Context, limit int) ([]Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), limit)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return snippets, nil
+}
+
+// This will delete a snippet, whether or not it has expired. It returns
+// ErrNoRecord if there is no snippet with the given ID. Snippets owned by an
+// organisation are left alone, as they are by Get(); they're deleted with
+// OrgModel.DeleteSnippet(), which checks the user's membership.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM snippets WHERE id = ? AND org_id IS NULL`), id)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will return the number of snippets which haven't expired yet.
+func (m *SnippetModel) CountLive(ctx context.Context) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(`SELECT COUNT(*) FROM snippets WHERE expires > ?`), utcNow()).Scan(&count)
+	return count, queryError(ctx, err)
+}
@@ -0,0 +1,107 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestSnippetModelForUser(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db, Dialect: testDialect}
+
+	// Create three public snippets, plus an unlisted and a private one which
+	// must never be returned.
+	for _, visibility := range []string{SnippetPublic, SnippetUnlisted, SnippetPublic, SnippetPrivate, SnippetPublic} {
+		_, err := m.Insert(t.Context(), 1, "Title", "Content", 7, visibility)
+		assert.NilError(t, err)
+	}
+
+	snippets, hasNext, err := m.ForUser(t.Context(), 1, 1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, hasNext, true)
+
+	snippets, hasNext, err = m.ForUser(t.Context(), 1, 2, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, hasNext, false)
+
+	for _, s := range snippets {
+		assert.Equal(t, s.Visibility, SnippetPublic)
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db, Dialect: testDialect}
+
+	id, err := m.Insert(t.Context(), 1, "Title", "Content", 7, SnippetPublic)
+	assert.NilError(t, err)
+
+	err = m.Update(t.Context(), id, "New title", "New content", 1, SnippetPrivate)
+	assert.NilError(t, err)
+
+	s, err := m.Get(t.Context(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "New title")
+	assert.Equal(t, s.Visibility, SnippetPrivate)
+
+	// An expires of zero leaves the expiry time alone.
+	err = m.Update(t.Context(), id, "Newer title", "New content", 0, SnippetPrivate)
+	assert.NilError(t, err)
+
+	updated, err := m.Get(t.Context(), id)
+	assert.NilError(t, err)
+	assert.Equal(t, updated.Title, "Newer title")
+	assert.Equal(t, updated.Expires.Equal(s.Expires), true)
+
+	err = m.Update(t.Context(), id+1, "Title", "Content", 7, SnippetPublic)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelContext(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db, Dialect: testDialect}
+
+	// A query made with a context which has already been canceled (because
+	// the client went away, say) fails with ErrCanceled.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := m.Latest(ctx)
+	assert.Equal(t, errors.Is(err, ErrCanceled), true)
+
+	// A query which runs past the model's timeout fails with ErrTimeout.
+	m.QueryTimeout = time.Nanosecond
+
+	_, err = m.Latest(t.Context())
+	assert.Equal(t, errors.Is(err, ErrTimeout), true)
+
+	// And with a reasonable timeout, everything works as normal.
+	m.QueryTimeout = 5 * time.Second
+
+	_, err = m.Latest(t.Context())
+	assert.NilError(t, err)
+}
@@ -0,0 +1,7 @@
+INSERT INTO users (name, email, handle, hashed_password, created) VALUES (
+    'Alice Jones',
+    'alice@example.com',
+    'alice',
+    '$2a$12$EY58Cf5PHn58M1nij3WsxemVQyv7qoZtJGabpK/PsWGWcJfZRWJvS',
+    '2022-01-01 09:18:24'
+);
@@ -6,32 +6,51 @@ package models
 import (
 	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
+// testDialect is the dialect of the test database, picked from DB_DSN in the
+// same way as the application does. The integration tests can be run against
+// MySQL, PostgreSQL or SQLite, and the models they create must use it. If
+// DB_DSN isn't set, each test gets a new SQLite database in a temporary
+// directory, so the tests can be run without a database server.
+var testDialect = func() Dialect {
+	if os.Getenv("DB_DSN") == "" {
+		return SQLite
+	}
+	return DialectFromDSN(os.Getenv("DB_DSN"))
+}()
+
 func newTestDB(t *testing.T) *sql.DB {
 
-	// Establish a sql.DB connection pool for our test database. because our
-	// setup and teardown scripts contains multiple SQL statements, we need
-	// to use the "multiStatements=true" parameter in our DSN. This instructs
-	// our MySQL database driver to support executing multiple SQL stmts
-	// in one db.Exec() call.
+	// Establish a sql.DB connection pool for our test database.
 	dsn := os.Getenv("DB_DSN")
 	if dsn == "" {
-		t.Fatal("DB_DSN not set")
+		dsn = "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	}
+
+	db, err := sql.Open(testDialect.DriverName(), testDialect.DriverDSN(dsn))
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Ensure multiStatements=true for setup/teardown
-	dsn += "&multiStatements=true"
+	// Build the schema from the same migrations as the application uses,
+	// closing the connection pool and calling t.Fatal() in the event of an
+	// error.
+	migrator := &Migrator{DB: db, Dialect: testDialect}
 
-	db, err := sql.Open("mysql", dsn)
+	_, err = migrator.Up()
 	if err != nil {
+		db.Close()
 		t.Fatal(err)
 	}
 
-	// Read the setup SQL script from the file and execute the stmts, closing
-	// the connection pool and calling t.Fatat() in the event of an error.
-	script, err := os.ReadFile("./testdata/setup.sql")
+	// Then read the test data from the seed script and insert it.
+	script, err := os.ReadFile("./testdata/seed.sql")
 	if err != nil {
 		db.Close()
 		t.Fatal(err)
@@ -45,17 +64,23 @@ func newTestDB(t *testing.T) *sql.DB {
 
 	// Use t.Cleanup() to register a function *which will auto be
 	// called by Go when the current test (or sub-test) which calls newTestDB()
-	// has finished*. In this function we read and execute the teardown script,
-	// and close the database connection pool.
+	// has finished*. In this function we undo all of the migrations, which
+	// checks the down migrations too, drop the schema_migrations table, and
+	// close the database connection pool.
 	t.Cleanup(func() {
 		defer db.Close()
 
-		script, err := os.ReadFile("./testdata/teardown.sql")
+		latest, err := migrator.Latest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = migrator.Down(latest)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		_, err = db.Exec(string(script))
+		_, err = db.Exec(`DROP TABLE schema_migrations`)
 		if err != nil {
 			t.Fatal(err)
 		}
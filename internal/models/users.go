@@ -1,66 +1,107 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/High-la/snippetbox/internal/password"
 )
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
+	Insert(ctx context.Context, name, email, handle, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (User, error)
+	GetByHandle(ctx context.Context, handle string) (User, error)
+	Delete(ctx context.Context, id int, anonymiseSnippets bool) error
+	UpdatePassword(ctx context.Context, id int, plaintext string) error
+	SetRole(ctx context.Context, email, role string) error
+	Search(ctx context.Context, query string, limit int) ([]User, error)
+	SetSuspended(ctx context.Context, id int, suspended bool) error
+	Count(ctx context.Context) (int, error)
 }
 
+// The roles a user can have. Every user starts with RoleUser.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every valid role.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
 // Define a new User struct. Notice how the field names and types align
 // with the columns in the database "users" table ?
 type User struct {
 	ID             int
 	Name           string
 	Email          string
+	Handle         string // Empty if the user didn't choose a handle.
+	Role           string
+	Suspended      bool
 	HashedPassword []byte
 	Created        time.Time
 }
 
-// Define a new UserModel struct which wraps a database connection pool.
+// Define a new UserModel struct which wraps a database connection pool, the
+// dialect of the database, and the password hasher. If Hasher is nil, Argon2id
+// with the default parameters is used. If Replicas is set, Search() reads
+// from the read replicas.
 type UserModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+	Hasher       password.Hasher
+	Replicas     *Replicas // Nil if there are no read replicas.
+}
+
+func (m *UserModel) hasher() password.Hasher {
+	if m.Hasher == nil {
+		return password.DefaultArgon2id()
+	}
+	return m.Hasher
 }
 
 // We'll use the Insert method to add a new record to the "users" table.
-func (m *UserModel) Insert(name, email, password string) error {
+// The handle is optional, and an empty handle is stored as NULL so that the
+// unique constraint on the column only applies to users who chose one.
+func (m *UserModel) Insert(ctx context.Context, name, email, handle, plaintext string) error {
 
-	// Create a bcrypt hash of the plain-text password.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Hash the plain-text password with the configured hasher.
+	hashedPassword, err := m.hasher().Hash(plaintext)
 	if err != nil {
-		return err
+		return queryError(ctx, err)
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-			 VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, handle, hashed_password, created)
+			 VALUES(?, ?, ?, ?, ?)`
 
 	// Use the Exec() method to insert the user details and hashed password
 	// into the users table.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), name, email, sql.NullString{String: handle, Valid: handle != ""}, hashedPassword, utcNow())
 	if err != nil {
 
-		// If this returns an error, we use the errors.As() function to check
-		// wether the error has the type *mysql.MySQLError. if it does, the
-		// error will be assigned to the mySQLError variable. we can then check
-		// wether or not the error relates to our users_uc_email key by
-		// checking if the error code equals 1062 and the contents of the error
-		// message string. If it does, we return an ErrDuplicateEmail error.
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		// If this returns an error, we use the dialect's isUniqueViolation()
+		// helper to check whether the error relates to our users_uc_email
+		// constraint. MySQL and PostgreSQL report this differently (error
+		// 1062 and SQLSTATE 23505 respectively, and SQLite differently again),
+		// but the helper knows how to read them all. If it does, we return an
+		// ErrDuplicateEmail error. The same goes for the users_uc_handle
+		// constraint and ErrDuplicateHandle.
+		if m.Dialect.isUniqueViolation(err, "users_uc_email") {
+			return ErrDuplicateEmail
+		}
+		if m.Dialect.isUniqueViolation(err, "users_uc_handle") {
+			return ErrDuplicateHandle
 		}
-		return err
+		return queryError(ctx, err)
 	}
 
 	return nil
@@ -68,33 +109,56 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 // We'll use the Authenticate method to verify whether a user exists with
 // the provided email address and password. This will return the relevant
-// user ID if they do.
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+// user ID if they do. If the stored hash was created with a different
+// algorithm or cost to the current hasher, it is upgraded after a successful
+// login, while we have the plain-text password to hand.
+func (m *UserModel) Authenticate(ctx context.Context, email, plaintext string) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
 
 	// Retrieve the id and hashed password associated with the given email. If
 	// no matching email exists we return the ErrInvalidCredentials error.
 	var id int
-	var hashedPassword []byte
+	var hashedPassword string
+	var suspended bool
 
-	stmt := `SELECT id, hashed_password FROM users WHERE email = ?`
+	stmt := `SELECT id, hashed_password, suspended FROM users WHERE email = ?`
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), email).Scan(&id, &hashedPassword, &suspended)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
 		} else {
-			return 0, err
+			return 0, queryError(ctx, err)
 		}
 	}
 
 	// Check whether the hashed password and plain-text password provided match.
 	// If they don't, we return the ErrInvalidCredentials error.
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	match, needsRehash, err := m.hasher().Verify(plaintext, hashedPassword)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
-		} else {
-			return 0, err
+		return 0, queryError(ctx, err)
+	}
+	if !match {
+		return 0, ErrInvalidCredentials
+	}
+
+	// Only tell the user that their account is suspended once they've proved
+	// they know the password.
+	if suspended {
+		return 0, ErrUserSuspended
+	}
+
+	if needsRehash {
+		newHash, err := m.hasher().Hash(plaintext)
+		if err != nil {
+			return 0, queryError(ctx, err)
+		}
+
+		_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(`UPDATE users SET hashed_password = ? WHERE id = ?`), newHash, id)
+		if err != nil {
+			return 0, queryError(ctx, err)
 		}
 	}
 
@@ -103,12 +167,249 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 // We'll use the Exists method to check if a user exists with a specific ID.
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
 
 	var exists bool
 
 	stmt := `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
-	return exists, err
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), id).Scan(&exists)
+	return exists, queryError(ctx, err)
+}
+
+// We'll use the Get method to fetch the details of a specific user, without
+// their hashed password.
+func (m *UserModel) Get(ctx context.Context, id int) (User, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	return m.getUser(ctx, stmt, id)
+}
+
+// GetByHandle fetches the details of the user with the given handle.
+func (m *UserModel) GetByHandle(ctx context.Context, handle string) (User, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE handle = ?`
+
+	return m.getUser(ctx, stmt, handle)
+}
+
+// The userColumns constant and scanUser function keep the list of selected
+// columns and the fields they are scanned into in one place. The hashed
+// password is deliberately left out.
+const userColumns = `id, name, email, handle, role, suspended, created`
+
+func scanUser(row interface{ Scan(...any) error }) (User, error) {
+
+	var user User
+	var handle sql.NullString
+
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &handle, &user.Role, &user.Suspended, &user.Created)
+	if err != nil {
+		return User{}, err
+	}
+
+	user.Handle = handle.String
+
+	return user, nil
+}
+
+func (m *UserModel) getUser(ctx context.Context, stmt string, args ...any) (User, error) {
+
+	user, err := scanUser(m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		} else {
+			return User{}, queryError(ctx, err)
+		}
+	}
+
+	return user, nil
+}
+
+// We'll use the Delete method to remove a user and all of their data. Their
+// snippets are either deleted or anonymised (by clearing the owner), and their
+// sessions are purged from both the user_sessions and sessions tables. Everything
+// happens in a single transaction, so a failure part way through never leaves
+// orphaned data behind.
+func (m *UserModel) Delete(ctx context.Context, id int, anonymiseSnippets bool) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	// Calling Rollback() after a successful Commit() is a no-op, so it's safe
+	// to always defer it.
+	defer tx.Rollback()
+
+	// Snippets which belong to an organisation stay with it, but lose their
+	// author. Of the rest, private snippets can only ever be read by their
+	// owner, so there is nothing to keep once the owner has gone, even when
+	// anonymising.
+	stmts := []string{
+		`UPDATE snippets SET user_id = NULL WHERE user_id = ? AND org_id IS NOT NULL`,
+	}
+	if anonymiseSnippets {
+		stmts = append(stmts,
+			`DELETE FROM snippets WHERE user_id = ? AND visibility = 'private'`,
+			`UPDATE snippets SET user_id = NULL WHERE user_id = ?`,
+		)
+	} else {
+		stmts = append(stmts,
+			`DELETE FROM snippets WHERE user_id = ?`,
+		)
+	}
+
+	stmts = append(stmts,
+		`DELETE FROM org_members WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE user_id = ?`,
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)`,
+		`DELETE FROM webhooks WHERE user_id = ?`,
+		`DELETE FROM sessions WHERE token IN (SELECT token FROM user_sessions WHERE user_id = ?)`,
+		`DELETE FROM user_sessions WHERE user_id = ?`,
+	)
+
+	for _, stmt := range stmts {
+		_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), id)
+		if err != nil {
+			return queryError(ctx, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM users WHERE id = ?`), id)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return queryError(ctx, tx.Commit())
+}
+
+// We'll use the UpdatePassword method to replace a user's password.
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, plaintext string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	hashedPassword, err := m.hasher().Hash(plaintext)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	stmt := `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), hashedPassword, id)
+	return queryError(ctx, err)
+}
+
+// We'll use the SetRole method to change the role of the user with the given
+// email address. It returns ErrNoRecord if there is no such user.
+func (m *UserModel) SetRole(ctx context.Context, email, role string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Look the user up first, rather than relying on RowsAffected(), because
+	// MySQL reports zero affected rows when the role is already set.
+	var id int
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(`SELECT id FROM users WHERE email = ?`), email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return queryError(ctx, err)
+		}
+	}
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(`UPDATE users SET role = ? WHERE id = ?`), role, id)
+	return queryError(ctx, err)
+}
+
+// We'll use the Search method to find users whose name, email address or
+// handle contains the query. An empty query returns the newest users.
+func (m *UserModel) Search(ctx context.Context, query string, limit int) ([]User, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Escape the LIKE wildcards so that they're matched literally. The
+	// columns and the pattern are both lowercased, because LIKE is case
+	// sensitive in PostgreSQL, unlike MySQL. SQLite needs to be told that
+	// the backslash is the escape character.
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(query)) + "%"
+
+	like := `LIKE ?` + m.Dialect.likeEscape()
+
+	stmt := `SELECT ` + userColumns + ` FROM users
+			 WHERE LOWER(name) ` + like + ` OR LOWER(email) ` + like + ` OR LOWER(handle) ` + like + `
+			 ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.Replicas.reader(ctx, m.DB).QueryContext(ctx, m.Dialect.rebind(stmt), pattern, pattern, pattern, limit)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return users, nil
+}
+
+// We'll use the SetSuspended method to suspend or unsuspend a user. Suspended
+// users can't login, and their existing sessions stop working.
+func (m *UserModel) SetSuspended(ctx context.Context, id int, suspended bool) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `UPDATE users SET suspended = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), suspended, id)
+	return queryError(ctx, err)
+}
+
+// We'll use the Count method to return the total number of users.
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, queryError(ctx, err)
 }
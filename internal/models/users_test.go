@@ -4,9 +4,14 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/password"
 )
 
 func TestUserModelExists(t *testing.T) {
@@ -50,14 +55,278 @@ func TestUserModelExists(t *testing.T) {
 			db := newTestDB(t)
 
 			// Create a new instance of the UserModel.
-			m := UserModel{db}
+			m := UserModel{DB: db, Dialect: testDialect}
 
 			// Call the UserModel.Exists() method and check that the return
 			// value and error match the expected values for the sub-test.
-			exists, err := m.Exists(tt.userID)
+			exists, err := m.Exists(t.Context(), tt.userID)
 
 			assert.Equal(t, exists, tt.want)
 			assert.NilError(t, err)
 		})
 	}
 }
+
+func TestUserModelDelete(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name              string
+		anonymiseSnippets bool
+		wantSnippets      int
+	}{
+		{
+			name:              "Delete snippets",
+			anonymiseSnippets: false,
+			wantSnippets:      0,
+		},
+		{
+			name:              "Anonymise snippets",
+			anonymiseSnippets: true,
+			wantSnippets:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			db := newTestDB(t)
+
+			snippets := SnippetModel{DB: db, Dialect: testDialect}
+			userSessions := UserSessionModel{DB: db, Dialect: testDialect}
+			m := UserModel{DB: db, Dialect: testDialect}
+
+			publicID, err := snippets.Insert(t.Context(), 1, "Public", "Content", 7, SnippetPublic)
+			assert.NilError(t, err)
+
+			privateID, err := snippets.Insert(t.Context(), 1, "Private", "Content", 7, SnippetPrivate)
+			assert.NilError(t, err)
+
+			_, err = db.Exec(testDialect.rebind(`INSERT INTO sessions (token, data, expiry) VALUES ('alice-token', '', ?)`), utcNow())
+			assert.NilError(t, err)
+
+			_, err = userSessions.Insert(t.Context(), 1, "alice-token", "Browser", "192.0.2.1")
+			assert.NilError(t, err)
+
+			err = m.Delete(t.Context(), 1, tt.anonymiseSnippets)
+			assert.NilError(t, err)
+
+			exists, err := m.Exists(t.Context(), 1)
+			assert.NilError(t, err)
+			assert.Equal(t, exists, false)
+
+			// Private snippets are always removed.
+			_, err = snippets.Get(t.Context(), privateID)
+			assert.Equal(t, err, ErrNoRecord)
+
+			s, err := snippets.Get(t.Context(), publicID)
+			if tt.wantSnippets == 0 {
+				assert.Equal(t, err, ErrNoRecord)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, s.UserID, 0)
+			}
+
+			var count int
+			err = db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE token = 'alice-token'`).Scan(&count)
+			assert.NilError(t, err)
+			assert.Equal(t, count, 0)
+
+			sessions, err := userSessions.ForUser(t.Context(), 1)
+			assert.NilError(t, err)
+			assert.Equal(t, len(sessions), 0)
+		})
+	}
+}
+
+func TestUserModelAuthenticateRehash(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	// The down migrations can't shrink the hashed_password column while an
+	// Argon2id hash is stored in it, so put the seeded bcrypt hash back
+	// before they run.
+	var legacy string
+	err := db.QueryRow(`SELECT hashed_password FROM users WHERE id = 1`).Scan(&legacy)
+	assert.NilError(t, err)
+
+	t.Cleanup(func() {
+		_, err := db.Exec(testDialect.rebind(`UPDATE users SET hashed_password = ? WHERE id = 1`), legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// The seeded user has a legacy bcrypt hash, which should be replaced with
+	// an Argon2id hash after a successful login.
+	m := UserModel{DB: db, Dialect: testDialect, Hasher: &password.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+
+	id, err := m.Authenticate(t.Context(), "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	var hash string
+	err = db.QueryRow(`SELECT hashed_password FROM users WHERE id = 1`).Scan(&hash)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(hash, "$argon2id$"), true)
+
+	// The upgraded hash still works.
+	id, err = m.Authenticate(t.Context(), "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	_, err = m.Authenticate(t.Context(), "alice@example.com", "wrong")
+	assert.Equal(t, err, ErrInvalidCredentials)
+}
+
+func TestUserModelSetRole(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db, Dialect: testDialect}
+
+	user, err := m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Role, RoleUser)
+
+	err = m.SetRole(t.Context(), "alice@example.com", RoleAdmin)
+	assert.NilError(t, err)
+
+	// Setting the same role again is not an error.
+	err = m.SetRole(t.Context(), "alice@example.com", RoleAdmin)
+	assert.NilError(t, err)
+
+	user, err = m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Role, RoleAdmin)
+
+	err = m.SetRole(t.Context(), "nobody@example.com", RoleAdmin)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelInsert(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		email   string
+		handle  string
+		wantErr error
+	}{
+		{
+			name:   "Valid",
+			email:  "bob@example.com",
+			handle: "bob",
+		},
+		{
+			name:   "No handle",
+			email:  "bob@example.com",
+			handle: "",
+		},
+		{
+			name:    "Duplicate email",
+			email:   "alice@example.com",
+			handle:  "bob",
+			wantErr: ErrDuplicateEmail,
+		},
+		{
+			name:    "Duplicate handle",
+			email:   "bob@example.com",
+			handle:  "alice",
+			wantErr: ErrDuplicateHandle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			m := UserModel{DB: db, Dialect: testDialect, Hasher: &password.Bcrypt{Cost: 4}}
+
+			err := m.Insert(t.Context(), "Bob", tt.email, tt.handle, "pa$$word")
+			assert.Equal(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUserModelSearch(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db, Dialect: testDialect, Hasher: &password.Bcrypt{Cost: 4}}
+
+	err := m.Insert(t.Context(), "Bob 100% Smith", "bob@example.com", "bob_smith", "pa$$word")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+	}{
+		{name: "Empty", query: "", wantCount: 2},
+		{name: "Name", query: "alice", wantCount: 1},
+		{name: "Different case", query: "ALICE", wantCount: 1},
+		{name: "Email", query: "@example.com", wantCount: 2},
+		{name: "Literal percent", query: "100%", wantCount: 1},
+		{name: "Literal underscore", query: "_", wantCount: 1},
+		{name: "No match", query: "carol", wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := m.Search(t.Context(), tt.query, 10)
+			assert.NilError(t, err)
+			assert.Equal(t, len(users), tt.wantCount)
+		})
+	}
+}
+
+func TestUserModelContext(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{DB: db, Dialect: testDialect}
+
+	// The user lookups which authenticate every request stop with the
+	// request: a canceled context fails with ErrCanceled...
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := m.Get(ctx, 1)
+	assert.Equal(t, errors.Is(err, ErrCanceled), true)
+
+	_, err = m.GetByHandle(ctx, "alice")
+	assert.Equal(t, errors.Is(err, ErrCanceled), true)
+
+	// ...and a query which runs past the model's timeout fails with
+	// ErrTimeout.
+	m.QueryTimeout = time.Nanosecond
+
+	_, err = m.Get(t.Context(), 1)
+	assert.Equal(t, errors.Is(err, ErrTimeout), true)
+
+	// With a reasonable timeout, everything works as normal.
+	m.QueryTimeout = 5 * time.Second
+
+	user, err := m.Get(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Handle, "alice")
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+type UserSessionModelInterface interface {
+	Insert(ctx context.Context, userID int, token, userAgent, ip string) (string, error)
+	Get(ctx context.Context, id string) (UserSession, error)
+	ForUser(ctx context.Context, userID int) ([]UserSession, error)
+	Touch(ctx context.Context, id string) error
+	Revoke(ctx context.Context, userID int, id string) error
+	RevokeAllExcept(ctx context.Context, userID int, id string) error
+	Count(ctx context.Context) (int, error)
+}
+
+// Define a UserSession type to hold the device metadata recorded for each
+// authenticated session. The ID is a random value which we store in the scs
+// session data. We also keep the scs token, so that the matching row in the
+// sessions table can be purged when the user deletes their account.
+type UserSession struct {
+	ID        string
+	UserID    int
+	UserAgent string
+	IP        string
+	Created   time.Time
+	LastSeen  time.Time
+}
+
+// Define a UserSessionModel type which wraps a sql.DB connection pool, and the
+// dialect of the database it's connected to.
+type UserSessionModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+}
+
+// newSessionID returns a random, URL-safe 43 character identifier.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Insert records a new authenticated session for the user and returns its ID.
+func (m *UserSessionModel) Insert(ctx context.Context, userID int, token, userAgent, ip string) (string, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	id, err := newSessionID()
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	// The user_agent column is limited to 255 characters, so trim anything
+	// longer rather than failing the login.
+	if runes := []rune(userAgent); len(runes) > 255 {
+		userAgent = string(runes[:255])
+	}
+
+	stmt := `INSERT INTO user_sessions (id, user_id, token, user_agent, ip, created, last_seen)
+			 VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	now := utcNow()
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), id, userID, token, userAgent, ip, now, now)
+	if err != nil {
+		return "", queryError(ctx, err)
+	}
+
+	return id, nil
+}
+
+// Get returns the session with the given ID. Revoked sessions are deleted, so
+// they come back as ErrNoRecord.
+func (m *UserSessionModel) Get(ctx context.Context, id string) (UserSession, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, user_agent, ip, created, last_seen FROM user_sessions
+			 WHERE id = ?`
+
+	var s UserSession
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), id).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return UserSession{}, ErrNoRecord
+		} else {
+			return UserSession{}, queryError(ctx, err)
+		}
+	}
+
+	return s, nil
+}
+
+// ForUser returns all the active sessions for a user, most recently used first.
+func (m *UserSessionModel) ForUser(ctx context.Context, userID int) ([]UserSession, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, user_agent, ip, created, last_seen FROM user_sessions
+			 WHERE user_id = ? ORDER BY last_seen DESC`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+
+	for rows.Next() {
+		var s UserSession
+		err = rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return sessions, nil
+}
+
+// Touch updates the last seen time of a session.
+func (m *UserSessionModel) Touch(ctx context.Context, id string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `UPDATE user_sessions SET last_seen = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), utcNow(), id)
+	return queryError(ctx, err)
+}
+
+// Revoke ends a single session belonging to the user. The user ID is part of
+// the WHERE clause so that one user can never revoke another user's session.
+func (m *UserSessionModel) Revoke(ctx context.Context, userID int, id string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `DELETE FROM user_sessions WHERE user_id = ? AND id = ?`
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), userID, id)
+	return queryError(ctx, err)
+}
+
+// RevokeAllExcept ends every session belonging to the user apart from the one
+// with the given ID (normally the session making the request).
+func (m *UserSessionModel) RevokeAllExcept(ctx context.Context, userID int, id string) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `DELETE FROM user_sessions WHERE user_id = ? AND id <> ?`
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), userID, id)
+	return queryError(ctx, err)
+}
+
+// Count returns the number of authenticated sessions across all users.
+func (m *UserSessionModel) Count(ctx context.Context) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_sessions`).Scan(&count)
+	return count, queryError(ctx, err)
+}
@@ -0,0 +1,47 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestUserSessionModelRevoke(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserSessionModel{DB: db, Dialect: testDialect}
+
+	current, err := m.Insert(t.Context(), 1, "current-token", "Current Browser", "192.0.2.1")
+	assert.NilError(t, err)
+
+	other, err := m.Insert(t.Context(), 1, "other-token", "Other Browser", "192.0.2.2")
+	assert.NilError(t, err)
+
+	sessions, err := m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 2)
+
+	// Another user must not be able to revoke the session.
+	err = m.Revoke(t.Context(), 2, other)
+	assert.NilError(t, err)
+
+	_, err = m.Get(t.Context(), other)
+	assert.NilError(t, err)
+
+	err = m.RevokeAllExcept(t.Context(), 1, current)
+	assert.NilError(t, err)
+
+	_, err = m.Get(t.Context(), other)
+	assert.Equal(t, err, ErrNoRecord)
+
+	s, err := m.Get(t.Context(), current)
+	assert.NilError(t, err)
+	assert.Equal(t, s.UserAgent, "Current Browser")
+}
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+type WebhookModelInterface interface {
+	Insert(ctx context.Context, userID int, url string) (int, error)
+	ForUser(ctx context.Context, userID int) ([]Webhook, error)
+	Delete(ctx context.Context, userID, id int) error
+	InsertDelivery(ctx context.Context, webhookID int, event string) (int, error)
+	UpdateDelivery(ctx context.Context, d WebhookDelivery) error
+	Deliveries(ctx context.Context, userID, limit int) ([]WebhookDelivery, error)
+	ClaimExpiredSnippets(ctx context.Context, limit int) ([]Snippet, error)
+}
+
+// The events which webhooks are sent for.
+const (
+	WebhookSnippetCreated = "snippet.created"
+	WebhookSnippetUpdated = "snippet.updated"
+	WebhookSnippetDeleted = "snippet.deleted"
+	WebhookSnippetExpired = "snippet.expired"
+)
+
+// The states of a webhook delivery. Deliveries are pending until they either
+// succeed or run out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Define a Webhook type to hold a URL which a user has registered to receive
+// events about their snippets. Secret is used to sign the payloads, so that
+// the receiver can check that they came from us. Unlike API tokens it has to
+// be stored as it is, because we need it to make the signatures.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Created time.Time
+}
+
+// Define a WebhookDelivery type to hold one attempt to send an event to a
+// webhook, for the delivery log. StatusCode and Error describe the result of
+// the most recent attempt.
+type WebhookDelivery struct {
+	ID         int
+	WebhookID  int
+	URL        string
+	Event      string
+	Status     string
+	Attempts   int
+	StatusCode int
+	Error      string
+	Created    time.Time
+	Updated    time.Time
+}
+
+// Define a WebhookModel type which wraps a sql.DB connection pool, and the
+// dialect of the database it's connected to.
+type WebhookModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration // Zero means no limit beyond the caller's context.
+}
+
+// Insert registers a new webhook URL for the user, with a random secret, and
+// returns its ID.
+func (m *WebhookModel) Insert(ctx context.Context, userID int, url string) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return 0, queryError(ctx, err)
+	}
+
+	stmt := `INSERT INTO webhooks (user_id, url, secret, created) VALUES(?, ?, ?, ?)`
+
+	return m.Dialect.insert(ctx, m.DB, stmt, userID, url, hex.EncodeToString(b), utcNow())
+}
+
+// ForUser returns all of a user's webhooks, oldest first.
+func (m *WebhookModel) ForUser(ctx context.Context, userID int) ([]Webhook, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, url, secret, created FROM webhooks WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+
+	for rows.Next() {
+		var w Webhook
+		err = rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &w.Created)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes one of the user's webhooks, along with its delivery log. The
+// user ID is part of the WHERE clause so that one user can never delete
+// another user's webhook.
+func (m *WebhookModel) Delete(ctx context.Context, userID, id int) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM webhooks WHERE user_id = ? AND id = ?`), userID, id)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`), id)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+
+	return queryError(ctx, tx.Commit())
+}
+
+// InsertDelivery adds a pending delivery of an event to the delivery log, and
+// returns its ID.
+func (m *WebhookModel) InsertDelivery(ctx context.Context, webhookID int, event string) (int, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, status, attempts, status_code, error, created, updated)
+			 VALUES(?, ?, ?, 0, 0, '', ?, ?)`
+
+	now := utcNow()
+
+	return m.Dialect.insert(ctx, m.DB, stmt, webhookID, event, DeliveryPending, now, now)
+}
+
+// UpdateDelivery records the result of an attempt to send a delivery.
+func (m *WebhookModel) UpdateDelivery(ctx context.Context, d WebhookDelivery) error {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// The error column is limited to 255 characters.
+	if runes := []rune(d.Error); len(runes) > 255 {
+		d.Error = string(runes[:255])
+	}
+
+	stmt := `UPDATE webhook_deliveries SET status = ?, attempts = ?, status_code = ?, error = ?,
+			 updated = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), d.Status, d.Attempts, d.StatusCode, d.Error, utcNow(), d.ID)
+	return queryError(ctx, err)
+}
+
+// Deliveries returns the most recent deliveries to any of the user's webhooks,
+// newest first.
+func (m *WebhookModel) Deliveries(ctx context.Context, userID, limit int) ([]WebhookDelivery, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.status_code, d.error, d.created, d.updated
+			 FROM webhook_deliveries d INNER JOIN webhooks w ON w.id = d.webhook_id
+			 WHERE w.user_id = ? ORDER BY d.id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID, limit)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Event, &d.Status, &d.Attempts, &d.StatusCode, &d.Error, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimExpiredSnippets returns personal snippets which have expired since
+// their owner registered a webhook, and which no snippet.expired event has
+// been sent for yet. Each expiry is recorded in the webhook_expiries table as
+// it is claimed, so that it's only returned once, even if more than one copy
+// of the application is running. A snippet which is edited (which moves its
+// expiry date) can expire, and be claimed, again.
+func (m *WebhookModel) ClaimExpiredSnippets(ctx context.Context, limit int) ([]Snippet, error) {
+
+	ctx, cancel := queryContext(ctx, m.QueryTimeout)
+	defer cancel()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+			 WHERE s.expires <= ? AND s.org_id IS NULL
+			 AND EXISTS (SELECT 1 FROM webhooks w WHERE w.user_id = s.user_id AND w.created < s.expires)
+			 AND NOT EXISTS (SELECT 1 FROM webhook_expiries e WHERE e.snippet_id = s.id AND e.expires = s.expires)
+			 ORDER BY s.expires LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow(), limit)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	var candidates []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		candidates = append(candidates, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	// Claim each snippet by inserting its expiry. If another copy of the
+	// application got there first, the insert is ignored, doesn't affect any
+	// rows, and the snippet is skipped.
+	stmt = m.Dialect.insertIgnore(`INTO webhook_expiries (snippet_id, expires) VALUES(?, ?)`)
+
+	var snippets []Snippet
+
+	for _, s := range candidates {
+		result, err := m.DB.ExecContext(ctx, stmt, s.ID, s.Expires)
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		if n == 1 {
+			snippets = append(snippets, s)
+		}
+	}
+
+	return snippets, nil
+}
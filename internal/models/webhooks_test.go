@@ -0,0 +1,116 @@
+//go:build integration
+// +build integration
+
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+func TestWebhookModel(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := WebhookModel{DB: db, Dialect: testDialect}
+
+	id, err := m.Insert(t.Context(), 1, "https://example.com/hook")
+	assert.NilError(t, err)
+
+	webhooks, err := m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(webhooks), 1)
+	assert.Equal(t, webhooks[0].URL, "https://example.com/hook")
+	assert.Equal(t, len(webhooks[0].Secret), 64)
+
+	deliveryID, err := m.InsertDelivery(t.Context(), id, WebhookSnippetCreated)
+	assert.NilError(t, err)
+
+	err = m.UpdateDelivery(t.Context(), WebhookDelivery{ID: deliveryID, Status: DeliverySucceeded, Attempts: 2, StatusCode: 204})
+	assert.NilError(t, err)
+
+	deliveries, err := m.Deliveries(t.Context(), 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(deliveries), 1)
+	assert.Equal(t, deliveries[0].URL, "https://example.com/hook")
+	assert.Equal(t, deliveries[0].Status, DeliverySucceeded)
+	assert.Equal(t, deliveries[0].Attempts, 2)
+
+	// Other users can't see the deliveries, or delete the webhook.
+	deliveries, err = m.Deliveries(t.Context(), 2, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(deliveries), 0)
+
+	err = m.Delete(t.Context(), 2, id)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Delete(t.Context(), 1, id)
+	assert.NilError(t, err)
+
+	webhooks, err = m.ForUser(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(webhooks), 0)
+}
+
+func TestWebhookModelClaimExpiredSnippets(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := WebhookModel{DB: db, Dialect: testDialect}
+
+	// A snippet which expired before the webhook was registered, one which
+	// has expired since, one which hasn't expired yet, and an expired
+	// organisation snippet.
+	now := utcNow()
+	day := 24 * time.Hour
+
+	stmts := []struct {
+		stmt string
+		args []any
+	}{
+		{
+			`INSERT INTO webhooks (user_id, url, secret, created) VALUES (1, 'https://example.com/hook', ?, ?)`,
+			[]any{strings.Repeat("a", 64), now.Add(-day)},
+		},
+		{
+			`INSERT INTO snippets (user_id, title, content, created, expires) VALUES (1, 'Old', 'Old', ?, ?)`,
+			[]any{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			`INSERT INTO snippets (user_id, title, content, created, expires) VALUES (1, 'Expired', 'Expired', ?, ?)`,
+			[]any{now.Add(-2 * day), now.Add(-time.Hour)},
+		},
+		{
+			`INSERT INTO snippets (user_id, title, content, created, expires) VALUES (1, 'Live', 'Live', ?, ?)`,
+			[]any{now, now.Add(day)},
+		},
+		{
+			`INSERT INTO snippets (user_id, org_id, title, content, created, expires) VALUES (1, 1, 'Org', 'Org', ?, ?)`,
+			[]any{now.Add(-2 * day), now.Add(-time.Hour)},
+		},
+	}
+
+	for _, s := range stmts {
+		_, err := db.Exec(testDialect.rebind(s.stmt), s.args...)
+		assert.NilError(t, err)
+	}
+
+	snippets, err := m.ClaimExpiredSnippets(t.Context(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "Expired")
+
+	// Each expiry is only claimed once.
+	snippets, err = m.ClaimExpiredSnippets(t.Context(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
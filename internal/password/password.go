@@ -0,0 +1,194 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownFormat is returned when a stored hash isn't in a format that any
+// of our hashers understand.
+var ErrUnknownFormat = errors.New("password: unknown hash format")
+
+// The Hasher interface describes a password hashing algorithm. Verify()
+// reports whether the plain-text password matches the encoded hash, and also
+// whether the hash should be replaced because it was created with a different
+// algorithm or different parameters to the ones the hasher is using now.
+type Hasher interface {
+	Hash(plaintext string) (string, error)
+	Verify(plaintext, encoded string) (match bool, needsRehash bool, err error)
+}
+
+// Argon2id hashes passwords with Argon2id, encoding the result in the PHC
+// string format used by the reference implementation, for example:
+//
+//	$argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+//
+// Bcrypt hashes can still be verified, but always need rehashing.
+type Argon2id struct {
+	Memory      uint32 // Memory in KiB.
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2id returns the parameters recommended by RFC 9106 for
+// systems where using 64MB of memory per hash is acceptable.
+func DefaultArgon2id() *Argon2id {
+	return &Argon2id{
+		Memory:      64 * 1024,
+		Iterations:  1,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (a *Argon2id) Hash(plaintext string) (string, error) {
+
+	salt := make([]byte, a.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(plaintext), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(plaintext, encoded string) (bool, bool, error) {
+
+	if isBcrypt(encoded) {
+		match, err := verifyBcrypt(plaintext, encoded)
+		return match, true, err
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	match := verifyArgon2id(plaintext, params, salt, key)
+
+	needsRehash := params.Memory != a.Memory ||
+		params.Iterations != a.Iterations ||
+		params.Parallelism != a.Parallelism ||
+		uint32(len(salt)) != a.SaltLength ||
+		uint32(len(key)) != a.KeyLength
+
+	return match, needsRehash, nil
+}
+
+// Bcrypt hashes passwords with bcrypt at the given cost. It's kept so that
+// existing hashes can be verified, and so that deployments which can't spare
+// the memory Argon2id needs can opt to keep using it. Argon2id hashes can
+// still be verified, but always need rehashing.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b *Bcrypt) Hash(plaintext string) (string, error) {
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), b.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (b *Bcrypt) Verify(plaintext, encoded string) (bool, bool, error) {
+
+	if !isBcrypt(encoded) {
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		return verifyArgon2id(plaintext, params, salt, key), true, nil
+	}
+
+	match, err := verifyBcrypt(plaintext, encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, err
+	}
+
+	return match, cost != b.Cost, nil
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func verifyBcrypt(plaintext, encoded string) (bool, error) {
+
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plaintext))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func verifyArgon2id(plaintext string, params Argon2id, salt, key []byte) bool {
+
+	otherKey := argon2.IDKey([]byte(plaintext), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	// Use ConstantTimeCompare() so that the time taken doesn't reveal how
+	// much of the key matched.
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
+
+func decodeArgon2id(encoded string) (Argon2id, []byte, []byte, error) {
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	var params Argon2id
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,149 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+// Use small parameters so that the tests run quickly.
+func testArgon2id() *Argon2id {
+	return &Argon2id{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func TestArgon2id(t *testing.T) {
+
+	hasher := testArgon2id()
+
+	hash, err := hasher.Hash("pa$$word")
+	assert.NilError(t, err)
+	assert.StringContains(t, hash, "$argon2id$v=19$m=1024,t=1,p=1$")
+
+	// The same password hashed twice gives different results, because the
+	// salt is random.
+	other, err := hasher.Hash("pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, hash == other, false)
+
+	tests := []struct {
+		name            string
+		hasher          Hasher
+		plaintext       string
+		wantMatch       bool
+		wantNeedsRehash bool
+	}{
+		{
+			name:      "Correct password",
+			hasher:    hasher,
+			plaintext: "pa$$word",
+			wantMatch: true,
+		},
+		{
+			name:      "Wrong password",
+			hasher:    hasher,
+			plaintext: "password",
+			wantMatch: false,
+		},
+		{
+			name:            "Changed parameters",
+			hasher:          &Argon2id{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+			plaintext:       "pa$$word",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "Switched to bcrypt",
+			hasher:          &Bcrypt{Cost: 4},
+			plaintext:       "pa$$word",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := tt.hasher.Verify(tt.plaintext, hash)
+			assert.NilError(t, err)
+			assert.Equal(t, match, tt.wantMatch)
+			assert.Equal(t, needsRehash, tt.wantNeedsRehash)
+		})
+	}
+}
+
+func TestBcrypt(t *testing.T) {
+
+	hash, err := (&Bcrypt{Cost: 4}).Hash("pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(hash, "$2a$04$"), true)
+
+	tests := []struct {
+		name            string
+		hasher          Hasher
+		plaintext       string
+		wantMatch       bool
+		wantNeedsRehash bool
+	}{
+		{
+			name:      "Correct password",
+			hasher:    &Bcrypt{Cost: 4},
+			plaintext: "pa$$word",
+			wantMatch: true,
+		},
+		{
+			name:      "Wrong password",
+			hasher:    &Bcrypt{Cost: 4},
+			plaintext: "password",
+			wantMatch: false,
+		},
+		{
+			name:            "Changed cost",
+			hasher:          &Bcrypt{Cost: 5},
+			plaintext:       "pa$$word",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "Legacy hash with argon2id",
+			hasher:          testArgon2id(),
+			plaintext:       "pa$$word",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := tt.hasher.Verify(tt.plaintext, hash)
+			assert.NilError(t, err)
+			assert.Equal(t, match, tt.wantMatch)
+			assert.Equal(t, needsRehash, tt.wantNeedsRehash)
+		})
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "Empty", encoded: ""},
+		{name: "Plain text", encoded: "pa$$word"},
+		{name: "Wrong version", encoded: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{name: "Zero iterations", encoded: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := testArgon2id().Verify("pa$$word", tt.encoded)
+			assert.Equal(t, err, ErrUnknownFormat)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The BreachedList interface is implemented by anything which can tell us
+// whether a password is known to have appeared in a data breach.
+type BreachedList interface {
+	Contains(plaintext string) (bool, error)
+}
+
+// Policy holds the rules that new passwords must follow. A nil Breached list
+// disables the breached-password check.
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Breached      BreachedList
+}
+
+// DefaultPolicy returns a policy with the length limits recommended by NIST
+// SP 800-63B, no composition rules and no breached-password list.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinLength: 8,
+		MaxLength: 64,
+	}
+}
+
+// Check returns a message for each rule that the password breaks, in the order
+// the rules are listed in the Policy struct, so that the first message is the
+// most useful one to show. The breached-password list is only consulted if the
+// password passes every other rule.
+func (p *Policy) Check(plaintext string) ([]string, error) {
+
+	var problems []string
+
+	length := utf8.RuneCountInString(plaintext)
+	if length < p.MinLength {
+		problems = append(problems, fmt.Sprintf("This field must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		problems = append(problems, fmt.Sprintf("This field must be no more than %d characters long", p.MaxLength))
+	}
+	if p.RequireUpper && !strings.ContainsFunc(plaintext, unicode.IsUpper) {
+		problems = append(problems, "This field must contain an uppercase letter")
+	}
+	if p.RequireLower && !strings.ContainsFunc(plaintext, unicode.IsLower) {
+		problems = append(problems, "This field must contain a lowercase letter")
+	}
+	if p.RequireDigit && !strings.ContainsFunc(plaintext, unicode.IsDigit) {
+		problems = append(problems, "This field must contain a digit")
+	}
+	if p.RequireSymbol && !strings.ContainsFunc(plaintext, isSymbol) {
+		problems = append(problems, "This field must contain a symbol")
+	}
+
+	if len(problems) > 0 || p.Breached == nil {
+		return problems, nil
+	}
+
+	breached, err := p.Breached.Contains(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if breached {
+		problems = append(problems, "This password has appeared in a data breach, please choose a different one")
+	}
+
+	return problems, nil
+}
+
+func isSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// HashFile is a BreachedList backed by a text file of SHA-1 password hashes in
+// uppercase hex, sorted in ascending order, one per line. Anything after the
+// hash on each line (like the ":count" suffix in the Pwned Passwords "ordered
+// by hash" download) is ignored. The file is binary searched on disk, so even
+// very large lists use almost no memory and no network access is needed.
+type HashFile struct {
+	f    *os.File
+	size int64
+}
+
+// OpenHashFile opens the sorted hash file at path.
+func OpenHashFile(path string) (*HashFile, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &HashFile{f: f, size: info.Size()}, nil
+}
+
+// Close closes the underlying file.
+func (h *HashFile) Close() error {
+	return h.f.Close()
+}
+
+func (h *HashFile) Contains(plaintext string) (bool, error) {
+
+	sum := sha1.Sum([]byte(plaintext))
+	target := []byte(strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	// lo is always the offset of the start of a line, and no line starting at
+	// or after hi can hold the target.
+	lo, hi := int64(0), h.size
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, end, line, err := h.lineFrom(mid)
+		if err != nil {
+			return false, err
+		}
+
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		hash, _, _ := bytes.Cut(line, []byte(":"))
+
+		switch bytes.Compare(bytes.ToUpper(hash), target) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = end
+		default:
+			hi = start
+		}
+	}
+
+	return false, nil
+}
+
+// lineFrom finds the first line which starts at or after off, and returns its
+// start offset, the offset of the following line and its contents.
+func (h *HashFile) lineFrom(off int64) (int64, int64, []byte, error) {
+
+	start := off
+
+	// Unless we're at the very start of the file, skip forward to the byte
+	// after the next newline (looking from off-1, in case off is already the
+	// start of a line).
+	if off > 0 {
+		r := bufio.NewReader(io.NewSectionReader(h.f, off-1, h.size-off+1))
+		skipped, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return h.size, h.size, nil, nil
+		}
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		start = off - 1 + int64(len(skipped))
+	}
+
+	if start >= h.size {
+		return h.size, h.size, nil, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(h.f, start, h.size-start))
+	line, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return 0, 0, nil, err
+	}
+
+	end := start + int64(len(line))
+
+	return start, end, bytes.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,157 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/High-la/snippetbox/internal/assert"
+)
+
+// writeHashFile writes a sorted hash file containing the given passwords, in
+// the Pwned Passwords "HASH:COUNT" format, and returns its path.
+func writeHashFile(t *testing.T, passwords ...string) string {
+
+	var lines []string
+	for i, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestHashFile(t *testing.T) {
+
+	breached := []string{"password", "123456", "qwerty", "letmein", "dragon", "monkey", "football"}
+
+	h, err := OpenHashFile(writeHashFile(t, breached...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// Every password in the file must be found, whichever position it ends
+	// up in once sorted.
+	for _, p := range breached {
+		t.Run(p, func(t *testing.T) {
+			found, err := h.Contains(p)
+			assert.NilError(t, err)
+			assert.Equal(t, found, true)
+		})
+	}
+
+	for _, p := range []string{"", "correct horse battery staple", "Password"} {
+		t.Run("Not "+p, func(t *testing.T) {
+			found, err := h.Contains(p)
+			assert.NilError(t, err)
+			assert.Equal(t, found, false)
+		})
+	}
+}
+
+func TestHashFileEmpty(t *testing.T) {
+
+	h, err := OpenHashFile(writeHashFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	found, err := h.Contains("password")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+}
+
+func TestPolicyCheck(t *testing.T) {
+
+	h, err := OpenHashFile(writeHashFile(t, "password1", "Tr0ub4dor&3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		plaintext string
+		want      string
+	}{
+		{
+			name:      "Valid",
+			policy:    DefaultPolicy(),
+			plaintext: "correct horse",
+		},
+		{
+			name:      "Too short",
+			policy:    DefaultPolicy(),
+			plaintext: "short",
+			want:      "This field must be at least 8 characters long",
+		},
+		{
+			name:      "Too long",
+			policy:    DefaultPolicy(),
+			plaintext: strings.Repeat("a", 65),
+			want:      "This field must be no more than 64 characters long",
+		},
+		{
+			name:      "Missing uppercase",
+			policy:    &Policy{MinLength: 8, RequireUpper: true},
+			plaintext: "lowercase only",
+			want:      "This field must contain an uppercase letter",
+		},
+		{
+			name:      "Missing digit",
+			policy:    &Policy{MinLength: 8, RequireDigit: true},
+			plaintext: "NoDigitsHere",
+			want:      "This field must contain a digit",
+		},
+		{
+			name:      "Missing symbol",
+			policy:    &Policy{MinLength: 8, RequireSymbol: true},
+			plaintext: "NoSymbols123",
+			want:      "This field must contain a symbol",
+		},
+		{
+			name:      "Breached",
+			policy:    &Policy{MinLength: 8, RequireUpper: true, RequireDigit: true, RequireSymbol: true, Breached: h},
+			plaintext: "Tr0ub4dor&3",
+			want:      "This password has appeared in a data breach, please choose a different one",
+		},
+		{
+			name:      "Not breached",
+			policy:    &Policy{MinLength: 8, Breached: h},
+			plaintext: "password2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := tt.policy.Check(tt.plaintext)
+			assert.NilError(t, err)
+
+			if tt.want == "" {
+				assert.Equal(t, len(problems), 0)
+				return
+			}
+
+			if len(problems) == 0 {
+				t.Fatalf("got no problems; want %q", tt.want)
+			}
+			assert.Equal(t, problems[0], tt.want)
+		})
+	}
+}
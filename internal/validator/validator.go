@@ -77,6 +77,11 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 // variable is more performant than re-parsing the pattern each time we need it.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// HandleRX is the pattern for profile handles: 3 to 30 lowercase letters,
+// digits, underscores or hyphens, starting with a letter. Because a handle can
+// never be all digits, it can't be confused with a numeric user ID in URLs.
+var HandleRX = regexp.MustCompile("^[a-z][a-z0-9_-]{2,29}$")
+
 // MinChars() returns true if a value contains at least n characters.
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
@@ -0,0 +1,448 @@
+// Package webhooks sends signed JSON payloads to the webhook URLs which users
+// register, when their snippets are created, edited, deleted or expire.
+//
+// Deliveries are made by a pool of background workers, so that a slow or
+// broken receiver never holds up the request which caused the event. Each
+// delivery is retried with exponential backoff, and its progress is recorded
+// in the delivery log through the WebhookModel.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+// The headers sent with every delivery. The signature is the hex-encoded
+// HMAC-SHA256 of the request body, keyed with the webhook's secret and
+// prefixed with "sha256=".
+const (
+	EventHeader     = "X-Snippetbox-Event"
+	DeliveryHeader  = "X-Snippetbox-Delivery"
+	SignatureHeader = "X-Snippetbox-Signature"
+)
+
+// ErrClosed is returned by Notify() once Shutdown() has been called.
+var ErrClosed = errors.New("webhooks: dispatcher is shut down")
+
+// ErrPrivateAddress is the error for a delivery to a webhook URL which
+// resolves to an address on the server's own network.
+var ErrPrivateAddress = errors.New("webhooks: refusing to connect to a private address")
+
+// Sign returns the signature header value for a payload.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the correct signature for the payload.
+// Receivers written in Go can use it to check deliveries.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// The Payload type is the JSON body sent to webhooks. ID is the delivery ID,
+// which is also sent in the X-Snippetbox-Delivery header, and stays the same
+// across retries so that receivers can ignore duplicates.
+type Payload struct {
+	ID      int            `json:"id"`
+	Event   string         `json:"event"`
+	Created time.Time      `json:"created"`
+	Snippet models.Snippet `json:"snippet"`
+}
+
+// The Config type holds the settings for a Dispatcher. Zero values are
+// replaced with the defaults given in the comments.
+type Config struct {
+	Workers       int           // 4
+	QueueSize     int           // 1000
+	MaxAttempts   int           // 5
+	Backoff       time.Duration // 1 second, doubled after each failed attempt
+	Timeout       time.Duration // 10 seconds for each attempt
+	SweepInterval time.Duration // 1 minute between checks for expired snippets
+
+	// AllowPrivateTargets lets deliveries go to loopback, private and
+	// link-local addresses. Webhook URLs are chosen by users, so this is off
+	// by default, to stop them using the server to reach internal services
+	// or a cloud provider's metadata endpoint. It's for development and
+	// tests, where the receiver usually runs on localhost.
+	AllowPrivateTargets bool
+}
+
+// The job type holds a delivery waiting in the queue.
+type job struct {
+	delivery models.WebhookDelivery
+	secret   string
+	body     []byte
+}
+
+// The Dispatcher type queues and sends webhook deliveries.
+type Dispatcher struct {
+	webhooks models.WebhookModelInterface
+	logger   *slog.Logger
+	client   *http.Client
+	cfg      Config
+
+	queue chan job
+
+	// ctx is cancelled if Shutdown() runs out of time, to abandon any
+	// deliveries which are still in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// stop tells the expiry sweeper to stop.
+	stop chan struct{}
+
+	workers sync.WaitGroup
+	sweeper sync.WaitGroup
+
+	// mu guards closed, so that nothing is sent on the queue after it has
+	// been closed.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New returns a Dispatcher which records deliveries with the given model. The
+// workers aren't started until Start() is called.
+func New(webhooks models.WebhookModelInterface, logger *slog.Logger, cfg Config) *Dispatcher {
+
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = time.Minute
+	}
+
+	// The addresses are checked as each connection is made, after the host
+	// name has been resolved, so a host name which resolves to a private
+	// address (or starts to, after the URL was registered) is caught too.
+	// Proxies are turned off, as the dialer would only see the proxy's
+	// address.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refusePrivateAddresses,
+		}).DialContext
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		webhooks: webhooks,
+		logger:   logger,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			// Don't follow redirects. A receiver which redirects is treated
+			// as having failed, so that payloads are only ever sent to the
+			// URL which the user registered.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg:    cfg,
+		queue:  make(chan job, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start starts the delivery workers and the expiry sweeper.
+func (d *Dispatcher) Start() {
+
+	for range d.cfg.Workers {
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+			for j := range d.queue {
+				d.deliver(j)
+			}
+		}()
+	}
+
+	d.sweeper.Add(1)
+	go func() {
+		defer d.sweeper.Done()
+		d.sweep()
+	}()
+}
+
+// Notify queues a delivery of the event to each of the user's webhooks. It
+// doesn't wait for them to be sent. If the queue is full, the deliveries are
+// recorded as failed straight away rather than holding up the caller. The
+// context is used for recording the deliveries in the database.
+func (d *Dispatcher) Notify(ctx context.Context, userID int, event string, snippet models.Snippet) error {
+
+	webhooks, err := d.webhooks.ForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	for _, w := range webhooks {
+		id, err := d.webhooks.InsertDelivery(ctx, w.ID, event)
+		if err != nil {
+			return err
+		}
+
+		delivery := models.WebhookDelivery{ID: id, WebhookID: w.ID, URL: w.URL, Event: event, Status: models.DeliveryPending}
+
+		body, err := json.Marshal(Payload{
+			ID:      id,
+			Event:   event,
+			Created: time.Now().UTC(),
+			Snippet: snippet,
+		})
+		if err != nil {
+			return err
+		}
+
+		select {
+		case d.queue <- job{delivery: delivery, secret: w.Secret, body: body}:
+		default:
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = "delivery queue is full"
+			d.record(delivery)
+		}
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting new deliveries and waits for the queued ones to be
+// sent, including their retries. If ctx is done first, the deliveries still in
+// progress are abandoned (and recorded as failed), and ctx's error is
+// returned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+
+	// Stop the sweeper first, because it calls Notify().
+	close(d.stop)
+	d.sweeper.Wait()
+
+	d.mu.Lock()
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// The deliver() method sends a delivery, retrying with exponential backoff
+// until it succeeds or runs out of attempts. The delivery log is updated
+// after every attempt.
+func (d *Dispatcher) deliver(j job) {
+
+	delivery := j.delivery
+	backoff := d.cfg.Backoff
+
+	for {
+		delivery.Attempts++
+		delivery.StatusCode, delivery.Error = d.send(j)
+
+		switch {
+		case delivery.Error == "":
+			delivery.Status = models.DeliverySucceeded
+		case delivery.Attempts >= d.cfg.MaxAttempts:
+			delivery.Status = models.DeliveryFailed
+		}
+
+		d.record(delivery)
+
+		if delivery.Status != models.DeliveryPending {
+			if delivery.Status == models.DeliveryFailed {
+				d.logger.Warn("webhook delivery failed", "delivery", delivery.ID, "url", delivery.URL, "err", delivery.Error)
+			}
+			return
+		}
+
+		// Wait before retrying, unless Shutdown() has run out of time, in
+		// which case the delivery is abandoned.
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+			backoff *= 2
+		case <-d.ctx.Done():
+		}
+		timer.Stop()
+
+		if d.ctx.Err() != nil {
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = "abandoned at shutdown: " + delivery.Error
+			d.record(delivery)
+			return
+		}
+	}
+}
+
+// The send() method makes one attempt at a delivery. It returns the response
+// status code (or zero if there wasn't a response) and a description of the
+// problem, which is empty if the receiver responded with a 2xx status.
+func (d *Dispatcher) send(j job) (int, string) {
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, j.delivery.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhooks/1.0")
+	req.Header.Set(EventHeader, j.delivery.Event)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(j.delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(j.secret, j.body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer res.Body.Close()
+
+	// Read (a limited amount of) the body, so that the connection can be
+	// reused.
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Sprintf("receiver responded with %s", res.Status)
+	}
+
+	return res.StatusCode, ""
+}
+
+// The record() method saves the state of a delivery in the delivery log. It
+// can only log errors, because there's nobody to return them to. It doesn't
+// use d.ctx, because abandoned deliveries still need to be recorded after
+// d.ctx has been cancelled.
+func (d *Dispatcher) record(delivery models.WebhookDelivery) {
+
+	err := d.webhooks.UpdateDelivery(context.Background(), delivery)
+	if err != nil {
+		d.logger.Error("recording webhook delivery", "delivery", delivery.ID, "err", err)
+	}
+}
+
+// The sweep() method regularly checks for snippets which have expired, and
+// sends a snippet.expired event for each of them, until Shutdown() is called.
+func (d *Dispatcher) sweep() {
+
+	ticker := time.NewTicker(d.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.sweepExpired()
+		}
+	}
+}
+
+// The sweepExpired() method sends a snippet.expired event for each snippet
+// which has expired since the last sweep.
+func (d *Dispatcher) sweepExpired() {
+
+	snippets, err := d.webhooks.ClaimExpiredSnippets(context.Background(), 100)
+	if err != nil {
+		d.logger.Error("claiming expired snippets", "err", err)
+		return
+	}
+
+	for _, s := range snippets {
+		err := d.Notify(context.Background(), s.UserID, models.WebhookSnippetExpired, s)
+		if err != nil {
+			d.logger.Error("notifying webhooks", "snippet", s.ID, "err", err)
+		}
+	}
+}
+
+// sharedAddressSpace is 100.64.0.0/10, which carrier-grade NAT uses, and
+// which some cloud providers put internal services on. It's not counted as
+// private by netip.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// The isPrivateAddress() function reports whether an address is one which
+// webhooks aren't allowed to connect to: a loopback, private, link-local,
+// multicast or unspecified address, or one in the shared address space. IPv4
+// addresses mapped into IPv6 are checked as IPv4.
+func isPrivateAddress(addr netip.Addr) bool {
+
+	addr = addr.Unmap()
+
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// The refusePrivateAddresses() function is a net.Dialer Control function,
+// which is called with the resolved address just before each connection is
+// made, and stops the connection if the address is a private one.
+func refusePrivateAddresses(network, address string, c syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if isPrivateAddress(addr) {
+		return fmt.Errorf("%w %s", ErrPrivateAddress, addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,313 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/High-la/snippetbox/internal/assert"
+	"github.com/High-la/snippetbox/internal/mocks"
+	"github.com/High-la/snippetbox/internal/models"
+)
+
+var testSnippet = models.Snippet{
+	ID:         1,
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Visibility: models.SnippetPublic,
+}
+
+// The receiver type is an httptest server which records the deliveries it
+// receives. It responds with the status codes in statuses, in order, and
+// with 200 OK once they run out.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+
+	rcv := &receiver{statuses: statuses}
+
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+
+		if len(rcv.statuses) > 0 {
+			w.WriteHeader(rcv.statuses[0])
+			rcv.statuses = rcv.statuses[1:]
+		}
+	}))
+	t.Cleanup(rcv.Close)
+
+	return rcv
+}
+
+func (rcv *receiver) count() int {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return len(rcv.requests)
+}
+
+// The newTestDispatcher helper returns a started dispatcher, with short
+// backoffs, which sends user 1's events to url. Any expired snippets given
+// are returned by the first sweep. The test receivers listen on 127.0.0.1, so
+// private targets are allowed.
+func newTestDispatcher(t *testing.T, url string, cfg Config, expired ...models.Snippet) (*Dispatcher, *mocks.WebhookModel) {
+
+	m := &mocks.WebhookModel{
+		Webhooks: []models.Webhook{{ID: 1, UserID: 1, URL: url, Secret: "s3cret"}},
+		Expired:  expired,
+	}
+
+	if cfg.Backoff == 0 {
+		cfg.Backoff = time.Millisecond
+	}
+	cfg.AllowPrivateTargets = true
+
+	d := New(m, slog.New(slog.DiscardHandler), cfg)
+	d.Start()
+
+	return d, m
+}
+
+func shutdown(t *testing.T, d *Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := d.Shutdown(ctx)
+	assert.NilError(t, err)
+}
+
+func TestSign(t *testing.T) {
+
+	body := []byte(`{"event":"snippet.created"}`)
+	signature := Sign("s3cret", body)
+
+	assert.Equal(t, len(signature), len("sha256=")+64)
+	assert.Equal(t, Verify("s3cret", body, signature), true)
+	assert.Equal(t, Verify("wrong", body, signature), false)
+	assert.Equal(t, Verify("s3cret", []byte(`{}`), signature), false)
+}
+
+func TestDeliver(t *testing.T) {
+
+	rcv := newReceiver(t)
+	d, m := newTestDispatcher(t, rcv.URL, Config{})
+
+	err := d.Notify(t.Context(), 1, models.WebhookSnippetCreated, testSnippet)
+	assert.NilError(t, err)
+
+	// Events for users without webhooks aren't sent anywhere.
+	err = d.Notify(t.Context(), 2, models.WebhookSnippetCreated, testSnippet)
+	assert.NilError(t, err)
+
+	// Shutdown() waits for the queued delivery to be sent.
+	shutdown(t, d)
+
+	assert.Equal(t, rcv.count(), 1)
+
+	r, body := rcv.requests[0], rcv.bodies[0]
+
+	assert.Equal(t, r.Method, http.MethodPost)
+	assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+	assert.Equal(t, r.Header.Get(EventHeader), models.WebhookSnippetCreated)
+	assert.Equal(t, r.Header.Get(DeliveryHeader), "1")
+	assert.Equal(t, Verify("s3cret", body, r.Header.Get(SignatureHeader)), true)
+
+	var payload Payload
+	err = json.Unmarshal(body, &payload)
+	assert.NilError(t, err)
+	assert.Equal(t, payload.ID, 1)
+	assert.Equal(t, payload.Event, models.WebhookSnippetCreated)
+	assert.Equal(t, payload.Snippet.Title, testSnippet.Title)
+
+	delivery := m.Delivery(1)
+	assert.Equal(t, delivery.Status, models.DeliverySucceeded)
+	assert.Equal(t, delivery.Attempts, 1)
+	assert.Equal(t, delivery.StatusCode, http.StatusOK)
+	assert.Equal(t, len(m.Log), 1)
+}
+
+func TestRetries(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		statuses       []int
+		wantStatus     string
+		wantAttempts   int
+		wantStatusCode int
+	}{
+		{
+			name:           "Succeeds after retrying",
+			statuses:       []int{http.StatusInternalServerError, http.StatusBadGateway},
+			wantStatus:     models.DeliverySucceeded,
+			wantAttempts:   3,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "Gives up",
+			statuses:       []int{500, 500, 500, 500},
+			wantStatus:     models.DeliveryFailed,
+			wantAttempts:   3,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "Redirects aren't followed",
+			statuses:       []int{http.StatusFound, http.StatusFound, http.StatusFound},
+			wantStatus:     models.DeliveryFailed,
+			wantAttempts:   3,
+			wantStatusCode: http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rcv := newReceiver(t, tt.statuses...)
+			d, m := newTestDispatcher(t, rcv.URL, Config{MaxAttempts: 3})
+
+			err := d.Notify(t.Context(), 1, models.WebhookSnippetUpdated, testSnippet)
+			assert.NilError(t, err)
+
+			shutdown(t, d)
+
+			delivery := m.Delivery(1)
+			assert.Equal(t, delivery.Status, tt.wantStatus)
+			assert.Equal(t, delivery.Attempts, tt.wantAttempts)
+			assert.Equal(t, delivery.StatusCode, tt.wantStatusCode)
+			assert.Equal(t, rcv.count(), tt.wantAttempts)
+		})
+	}
+}
+
+func TestPrivateTargets(t *testing.T) {
+
+	rcv := newReceiver(t)
+
+	m := &mocks.WebhookModel{
+		Webhooks: []models.Webhook{{ID: 1, UserID: 1, URL: rcv.URL, Secret: "s3cret"}},
+	}
+
+	// By default, deliveries to the receiver on 127.0.0.1 are refused
+	// before a connection is made.
+	d := New(m, slog.New(slog.DiscardHandler), Config{MaxAttempts: 1})
+	d.Start()
+
+	err := d.Notify(t.Context(), 1, models.WebhookSnippetCreated, testSnippet)
+	assert.NilError(t, err)
+
+	shutdown(t, d)
+
+	delivery := m.Delivery(1)
+	assert.Equal(t, delivery.Status, models.DeliveryFailed)
+	assert.StringContains(t, delivery.Error, "refusing to connect to a private address 127.0.0.1")
+	assert.Equal(t, rcv.count(), 0)
+}
+
+func TestIsPrivateAddress(t *testing.T) {
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.100.100.200", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "224.0.0.1", want: true},
+		{addr: "::1", want: true},
+		{addr: "::", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "fd00:ec2::254", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "93.184.215.14", want: false},
+		{addr: "172.32.0.1", want: false},
+		{addr: "2606:2800:21f:cb07:6820:80da:af6b:8b2c", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, isPrivateAddress(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+
+	// A receiver which never responds.
+	block := make(chan struct{})
+	rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer rcv.Close()
+	defer close(block)
+
+	d, m := newTestDispatcher(t, rcv.URL, Config{})
+
+	err := d.Notify(t.Context(), 1, models.WebhookSnippetDeleted, testSnippet)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = d.Shutdown(ctx)
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	delivery := m.Delivery(1)
+	assert.Equal(t, delivery.Status, models.DeliveryFailed)
+	assert.StringContains(t, delivery.Error, "abandoned at shutdown")
+
+	err = d.Notify(t.Context(), 1, models.WebhookSnippetDeleted, testSnippet)
+	assert.Equal(t, err, ErrClosed)
+}
+
+func TestSweepExpired(t *testing.T) {
+
+	expired := testSnippet
+	expired.ID = 7
+
+	rcv := newReceiver(t)
+	d, _ := newTestDispatcher(t, rcv.URL, Config{SweepInterval: time.Millisecond}, expired)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rcv.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	shutdown(t, d)
+
+	assert.Equal(t, rcv.count(), 1)
+	assert.Equal(t, rcv.requests[0].Header.Get(EventHeader), models.WebhookSnippetExpired)
+
+	var payload Payload
+	err := json.Unmarshal(rcv.bodies[0], &payload)
+	assert.NilError(t, err)
+	assert.Equal(t, payload.Snippet.ID, 7)
+}
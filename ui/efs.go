@@ -7,6 +7,8 @@ import "embed"
 // instructs Go to store the files from our ui/static folder in an embedded filesystem
 // referenced by the global variable Files.
 // here embeds the dir ui/static
+//
+// The "api" folder holds the OpenAPI document describing the JSON API.
 
-//go:embed "html" "static"
+//go:embed "html" "static" "api"
 var Files embed.FS